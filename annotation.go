@@ -0,0 +1,136 @@
+package rv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Annotation interface {
+	annotate(*function) error
+}
+
+func NoSkipDryRun() Annotation {
+	return annotationFunc(func(f *function) error {
+		f.noSkipDryRun = true
+		return nil
+	})
+}
+
+func NoAutoClose() Annotation {
+	return annotationFunc(func(f *function) error {
+		f.noAutoClose = true
+		return nil
+	})
+}
+
+// Priority chooses the provider with the highest priority among multiple provides of a type
+// and orders values of variadic parameters.
+func Priority(priority int) Annotation {
+	return annotationFunc(func(f *function) error {
+		f.priority = priority
+		return nil
+	})
+}
+
+// As additionally provides an output under each of the given interfaces,
+// passed as pointers like new(Interface), which the output implements.
+// Implementations are checked statically when options are applied.
+func As(ifaces ...any) Annotation {
+	return annotationFunc(func(f *function) error {
+		for _, iface := range ifaces {
+			ptr := reflect.TypeOf(iface)
+			if ptr == nil || ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Interface {
+				return fmt.Errorf("%w: As expects pointers to interfaces, got %T", ErrUnsupportedAnnotation, iface)
+			}
+			if err := f.bindAs(ptr.Elem()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Feeds provides the outputs of an invoke to other functions. The invoke
+// is called before its consumers, even if it is registered after them.
+func Feeds() Annotation {
+	return annotationFunc(func(f *function) error {
+		if !f.invoke {
+			return fmt.Errorf("%w: Feeds is applicable to invokes only", ErrUnsupportedAnnotation)
+		}
+		f.feeds = true
+		return nil
+	})
+}
+
+// Cleanup calls the func() output of a function when the context is done instead of providing it,
+// in reverse construction order together with WithAutoClose. A provide without other outputs
+// is called like an invoke.
+func Cleanup() Annotation {
+	return annotationFunc(func(f *function) error {
+		for i, out := range f.outputs {
+			if out.typ == cleanupFuncType {
+				f.outputs[i].cleanup = true
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: Cleanup expects a func() output of %s", ErrUnsupportedAnnotation, f.String())
+	})
+}
+
+// SkipUnmetMembers leaves out values of a variadic parameter whose providers have unmet dependencies,
+// with a warning, instead of failing. It suits plugins depending on optional configuration.
+func SkipUnmetMembers() Annotation {
+	return annotationFunc(func(f *function) error {
+		if len(f.inputs) == 0 || !f.inputs[len(f.inputs)-1].variadic {
+			return fmt.Errorf("%w: SkipUnmetMembers is applicable to variadic functions only", ErrUnsupportedAnnotation)
+		}
+		f.skipUnmet = true
+		return nil
+	})
+}
+
+// ProvidesFunc suppresses the warning about a provider without inputs returning a function,
+// which otherwise looks like a function-typed value mistakenly passed to Provide instead of Supply.
+func ProvidesFunc() Annotation {
+	return annotationFunc(func(f *function) error {
+		f.providesFunc = true
+		return nil
+	})
+}
+
+type annotationFunc func(*function) error
+
+func (af annotationFunc) annotate(f *function) error {
+	return af(f)
+}
+
+type annotatedTarget struct {
+	target      any
+	annotations []Annotation
+}
+
+func splitAnnotations(targets []any) ([]annotatedTarget, error) {
+	result := make([]annotatedTarget, 0, len(targets))
+	for _, target := range targets {
+		annotation, ok := target.(Annotation)
+		if !ok {
+			result = append(result, annotatedTarget{target: target})
+			continue
+		}
+		if len(result) == 0 {
+			return nil, fmt.Errorf("%w: annotation has no target", ErrUnsupportedAnnotation)
+		}
+		last := &result[len(result)-1]
+		last.annotations = append(last.annotations, annotation)
+	}
+	return result, nil
+}
+
+func applyAnnotations(f *function, annotations []Annotation) error {
+	for _, annotation := range annotations {
+		if err := annotation.annotate(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package rv
+
+import "reflect"
+
+// Bundle is a set of options with functions parsed once,
+// it is applied as an Option any number of times.
+type Bundle struct {
+	entries []bundleEntry
+}
+
+// bundleEntry is either parsed functions or an option applied as is.
+type bundleEntry struct {
+	provides []*function
+	invokes  []*function
+	opt      Option
+}
+
+func NewBundle(opts ...Option) (*Bundle, error) {
+	b := &Bundle{}
+	if err := b.add(opts); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Bundle) add(opts []Option) error {
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if group, ok := opt.(optionGroup); ok {
+			if err := b.add(group); err != nil {
+				return err
+			}
+			continue
+		}
+		scratch := &revolver{}
+		if err := opt.apply(scratch); err != nil {
+			return err
+		}
+		entry := bundleEntry{provides: scratch.provides, invokes: scratch.invokes}
+		scratch.provides, scratch.invokes = nil, nil
+		if !reflect.DeepEqual(scratch, &revolver{}) { // option changes settings too
+			entry = bundleEntry{opt: opt}
+		}
+		b.entries = append(b.entries, entry)
+	}
+	return nil
+}
+
+func (b *Bundle) apply(rv *revolver) error {
+	clones := make(map[*function]*function) // feeding invokes are both provides and invokes
+	clone := func(fn *function) *function {
+		if _, ok := clones[fn]; !ok {
+			clones[fn] = fn.clone()
+		}
+		return clones[fn]
+	}
+	for _, entry := range b.entries {
+		if entry.opt != nil {
+			if err := entry.opt.apply(rv); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, fn := range entry.provides {
+			rv.provides = append(rv.provides, clone(fn))
+		}
+		for _, fn := range entry.invokes {
+			rv.invokes = append(rv.invokes, clone(fn))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package rv
+
+import "reflect"
+
+// Caller is injected with the name of the function requesting it, e.g. to tag logs of a logger factory.
+// A provider requesting Caller receives the name of its direct consumer and is called once per consumer.
+// Only that provider is constructed per consumer: a value built from its output, e.g. a service using
+// a tagged logger, is still shared and the logger is tagged with the service constructor.
+// Invokes receive their own name.
+type Caller string
+
+var callerType = reflect.TypeOf(Caller(""))
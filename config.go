@@ -0,0 +1,37 @@
+package rv
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ProvideConfig provides T decoded from the value at key of the supplied map[string]any config.
+func ProvideConfig[T any](key string) Option {
+	return optionFunc(func(rv *revolver) error {
+		provide, err := parseProvide(func(config map[string]any) (T, error) {
+			return decodeConfig[T](config, key)
+		})
+		if err != nil {
+			return err
+		}
+		provide.name = fmt.Sprintf("ProvideConfig[%s](%q)", reflect.TypeOf((*T)(nil)).Elem(), key)
+		rv.provides = append(rv.provides, provide)
+		return nil
+	})
+}
+
+func decodeConfig[T any](config map[string]any, key string) (target T, _ error) {
+	value, ok := config[key]
+	if !ok {
+		return target, fmt.Errorf("%w: key %q for %T: not found", ErrConfigDecode, key, target)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return target, fmt.Errorf("%w: key %q for %T: %v", ErrConfigDecode, key, target, err)
+	}
+	if err = json.Unmarshal(data, &target); err != nil {
+		return target, fmt.Errorf("%w: key %q for %T: %v", ErrConfigDecode, key, target, err)
+	}
+	return target, nil
+}
@@ -16,6 +16,8 @@ func TestProject1(t *testing.T) {
 	err := rv.Revolve(ctx, rv.Options(
 		rv.WithLogger(rv.LogFunc(func(lvl rv.LogLevel, format string, args ...any) {
 			switch lvl {
+			case rv.LogLevelWarn:
+				log.Printf("customLogFunc: warn:"+format, args...)
 			case rv.LogLevelInfo:
 				log.Printf("customLogFunc: "+format, args...)
 			case rv.LogLevelDebug:
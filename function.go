@@ -7,7 +7,9 @@ import (
 	"net/url"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -22,45 +24,402 @@ const (
 
 type function struct {
 	targetFunc reflect.Value // maybe empty when values are provided by Supply
+	name       string        // overrides targetFunc name for synthetic functions
 	inputs     []input
 	outputs    []output
 	state      functionState
+	spent      time.Duration // duration of the last call
+
+	noSkipDryRun bool // called even in dry run mode
+	noAutoClose  bool // outputs are not closed by WithAutoClose
+	priority     int  // the highest priority wins among multiple provides
+	supplied     bool // provided by Supply
+	invoke       bool // outputs are provided only when feeds
+	feeds        bool
+	providesFunc bool                    // function output is intended, see ProvidesFunc
+	skipUnmet    bool                    // variadic values with unmet dependencies are skipped
+	converter    bool                    // provides only types which are not provided by other functions
+	synthetic    bool                    // registered by rv itself, matched by exact type only
+	cases        map[any]*function       // select cases by the selector value
+	resolveCase  func(*function) error   // links and calls the chosen select case, set by linking
+	leased       bool                    // called again when outputs are consumed after expires
+	expires      time.Time               // set by calls of leased functions
+	caller       string                  // the consumer of the per consumer clone, see Caller
+	callerClones map[*function]*function // per consumer clones of providers requesting Caller
+	consumed     bool                    // linked as a provider of some input
 }
 
 type input struct {
 	typ         reflect.Type
 	provider    *function
 	outputIndex int
+	variadic    bool         // collects all provided values of the slice element type
+	group       []outputRef  // linked providers of the variadic input
+	accessor    func() error // resolves the provider on demand for func() T inputs
 }
 
 type output struct {
-	typ   reflect.Type
-	value reflect.Value
+	typ     reflect.Type
+	value   reflect.Value
+	as      bool // bound by As annotation to the source output
+	source  int
+	cleanup bool // registered by Cleanup annotation instead of being provided
+	expiry  bool // expiry of a leased function, see ProvideLeased
+}
+
+// cleanupOnly reports whether the function has nothing to provide except a cleanup.
+func (f *function) cleanupOnly() bool {
+	cleanup := false
+	for _, out := range f.outputs {
+		if out.injectable() {
+			return false
+		}
+		cleanup = cleanup || out.cleanup
+	}
+	return cleanup
+}
+
+// injectable reports whether the output can be passed to other functions.
+func (o output) injectable() bool {
+	return !isErrorType(o.typ) && !o.cleanup && !o.expiry
+}
+
+type outputRef struct {
+	provider    *function
+	outputIndex int
+}
+
+func (r outputRef) String() string {
+	kind := ""
+	if r.provider.feeds {
+		kind = "feeding invoke "
+	}
+	out := r.provider.outputs[r.outputIndex]
+	if out.as {
+		return fmt.Sprintf("As(%s) binding of output #%d %s of %s%s",
+			out.typ, out.source, r.provider.outputs[out.source].typ, kind, r.provider.String())
+	}
+	return fmt.Sprintf("output #%d %s of %s%s", r.outputIndex, out.typ, kind, r.provider.String())
 }
 
-func (f *function) LinkProvides(provides []*function, assignable typesAssignableFunc) (providers []*function, _ error) {
+type outputIndex map[reflect.Type][]outputRef
+
+func newOutputIndex(provides []*function) outputIndex {
+	index := make(outputIndex)
+	for _, provide := range provides {
+		for outIndex, out := range provide.outputs {
+			if !out.injectable() {
+				continue
+			}
+			index[out.typ] = append(index[out.typ], outputRef{provider: provide, outputIndex: outIndex})
+		}
+	}
+	return index
+}
+
+// linker describes how function inputs are linked to provides.
+type linker struct {
+	provides     []*function
+	index        outputIndex // exact types lookup, provides are scanned with assignable func when nil
+	assignable   typesAssignableFunc
+	strictSupply bool // supplied values are matched by exact type
+	logger       Logger
+	resolve      func(provider *function, path []string) error // links and calls the provider on demand
+	register     func(provider, clone *function)               // tracks per consumer clones of the provider
+}
+
+func (f *function) LinkProvides(l linker) (providers []*function, _ error) {
+	if f.cases != nil {
+		path := []string{f.Name()}
+		f.resolveCase = func(fn *function) error { return l.resolve(fn, path) }
+	}
 	providers = make([]*function, 0, len(f.inputs))
 	for inIndex, in := range f.inputs {
-		provider, outputIndex, err := f.linkInput(in.typ, provides, assignable)
+		if in.typ == callerType {
+			continue
+		}
+		if in.variadic {
+			group := f.linkCandidates(in.typ.Elem(), l)
+			if f.skipUnmet {
+				group = f.skipUnmetMembers(group, l)
+			}
+			sort.SliceStable(group, func(i, j int) bool {
+				return group[i].provider.priority > group[j].provider.priority
+			})
+			for i := range group {
+				group[i].provider = f.forConsumer(group[i].provider, l)
+				providers = append(providers, group[i].provider)
+			}
+			f.inputs[inIndex].group = group
+			continue
+		}
+		candidates := f.linkCandidates(in.typ, l)
+		ref, err := f.chooseCandidate(in.typ, candidates, l.logger)
 		if err != nil {
 			return nil, err
 		}
-		if provider == nil {
-			return nil, fmt.Errorf("linking: %w type=%s for func %s", ErrCannotProvideValue, in.typ, f.String())
+		if elem, ok := accessorType(in.typ); ok && ref.provider == nil {
+			ref, err = f.chooseCandidate(elem, f.linkCandidates(elem, l), l.logger)
+			if err != nil {
+				return nil, err
+			}
+			if ref.provider != nil {
+				ref.provider = f.forConsumer(ref.provider, l)
+				provider, path := ref.provider, []string{f.Name()}
+				f.inputs[inIndex].provider = ref.provider
+				f.inputs[inIndex].outputIndex = ref.outputIndex
+				f.inputs[inIndex].accessor = func() error { return l.resolve(provider, path) }
+				continue
+			}
+		}
+		if ref.provider == nil && f.returns(in.typ, l) {
+			return nil, &LinkError{Type: in.typ, Func: f.String(), Err: ErrSelfDependency}
+		}
+		if ref.provider == nil {
+			return nil, &LinkError{
+				Type:  in.typ,
+				Func:  f.String(),
+				Hints: hints(in.typ, l.provides),
+				Err:   ErrCannotProvideValue,
+			}
 		}
-		f.inputs[inIndex].provider = provider
-		f.inputs[inIndex].outputIndex = outputIndex
-		providers = append(providers, provider)
+		ref.provider = f.forConsumer(ref.provider, l)
+		f.inputs[inIndex].provider = ref.provider
+		f.inputs[inIndex].outputIndex = ref.outputIndex
+		providers = append(providers, ref.provider)
 	}
 	f.state = StateLinked
 	return
 }
 
+// forConsumer returns the instance of the provider linked to f and marks it as consumed. Providers
+// requesting Caller are cloned once per consumer, clones are registered with the linker.
+func (f *function) forConsumer(provider *function, l linker) *function {
+	if provider.invoke || !provider.requestsCaller() {
+		provider.consumed = true
+		return provider
+	}
+	if clone, ok := f.callerClones[provider]; ok {
+		return clone
+	}
+	clone := provider.clone()
+	clone.state = StateInitialized
+	clone.caller = f.Name()
+	clone.callerClones = nil
+	clone.consumed = true
+	if f.callerClones == nil {
+		f.callerClones = make(map[*function]*function)
+	}
+	f.callerClones[provider] = clone
+	if l.register != nil {
+		l.register(provider, clone)
+	}
+	return clone
+}
+
+func (f *function) requestsCaller() bool {
+	for _, in := range f.inputs {
+		if in.typ == callerType {
+			return true
+		}
+	}
+	return false
+}
+
+// accessorType returns T for func() T and func() (T, error) types.
+func accessorType(typ reflect.Type) (reflect.Type, bool) {
+	if typ.Kind() != reflect.Func || typ.NumIn() != 0 {
+		return nil, false
+	}
+	switch {
+	case typ.NumOut() == 1 && !isErrorType(typ.Out(0)):
+		return typ.Out(0), true
+	case typ.NumOut() == 2 && !isErrorType(typ.Out(0)) && isErrorType(typ.Out(1)):
+		return typ.Out(0), true
+	}
+	return nil, false
+}
+
+func (f *function) skipUnmetMembers(group []outputRef, l linker) []outputRef {
+	result := group[:0]
+	for _, ref := range group {
+		if typ, ok := ref.provider.unmetDependency(l, make(map[*function]bool)); ok {
+			l.logger.Printf(LogLevelWarn, "%s is skipped for func %s: type=%s cannot be provided",
+				ref.String(), f.String(), typ)
+			continue
+		}
+		result = append(result, ref)
+	}
+	return result
+}
+
+// reach collects the function and the providers it may consume: linked inputs are followed, inputs of
+// functions which are not linked yet, like select cases chosen at call time, are matched with candidates.
+func (f *function) reach(l linker, reached map[*function]bool) {
+	if reached[f] {
+		return
+	}
+	reached[f] = true
+	for _, fn := range f.cases {
+		fn.reach(l, reached)
+	}
+	for _, in := range f.inputs {
+		switch {
+		case in.typ == callerType:
+		case f.state >= StateLinked:
+			if in.provider != nil {
+				in.provider.reach(l, reached)
+			}
+			for _, ref := range in.group {
+				ref.provider.reach(l, reached)
+			}
+		case in.variadic:
+			for _, ref := range f.linkCandidates(in.typ.Elem(), l) {
+				ref.provider.reach(l, reached)
+			}
+		default:
+			ref, _ := f.chooseCandidate(in.typ, f.linkCandidates(in.typ, l), l.logger)
+			if elem, ok := accessorType(in.typ); ok && ref.provider == nil {
+				ref, _ = f.chooseCandidate(elem, f.linkCandidates(elem, l), l.logger)
+			}
+			if ref.provider != nil {
+				ref.provider.reach(l, reached)
+			}
+		}
+	}
+}
+
+// unmetDependency returns the first type which can't be provided to the function or its providers.
+// Ambiguous types are left to be reported by linking.
+func (f *function) unmetDependency(l linker, visited map[*function]bool) (reflect.Type, bool) {
+	if f.state >= StateLinked || visited[f] {
+		return nil, false
+	}
+	visited[f] = true
+	for _, in := range f.inputs {
+		if in.typ == callerType || in.variadic {
+			continue
+		}
+		candidates := f.linkCandidates(in.typ, l)
+		if elem, ok := accessorType(in.typ); ok && len(candidates) == 0 {
+			if len(f.linkCandidates(elem, l)) == 0 {
+				return in.typ, true
+			}
+			continue
+		}
+		ref, err := f.chooseCandidate(in.typ, candidates, LogFunc(devNull))
+		if err != nil {
+			continue
+		}
+		if ref.provider == nil {
+			return in.typ, true
+		}
+		if typ, ok := ref.provider.unmetDependency(l, visited); ok {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// provides reports whether the function itself provides the type.
+func (f *function) provides(typ reflect.Type, l linker) bool {
+	if f.invoke && !f.feeds {
+		return false
+	}
+	return f.returns(typ, l)
+}
+
+// returns reports whether the function returns the type, even if it is not provided.
+func (f *function) returns(typ reflect.Type, l linker) bool {
+	assignable := l.assignable
+	if l.index != nil {
+		assignable = typesSimpleAssignable
+	}
+	for _, out := range f.outputs {
+		if out.injectable() && assignable(out.typ, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *function) linkCandidates(typ reflect.Type, l linker) []outputRef {
+	if l.index != nil {
+		return preferDirect(f.indexedCandidates(typ, l.index))
+	}
+	return preferDirect(collapseAs(f.candidates(typ, l)))
+}
+
+// collapseAs leaves out outputs whose As bindings are candidates as well,
+// since both are the same value of the same provider.
+func collapseAs(candidates []outputRef) []outputRef {
+	bound := make(map[outputRef]bool)
+	for _, ref := range candidates {
+		if out := ref.provider.outputs[ref.outputIndex]; out.as {
+			bound[outputRef{provider: ref.provider, outputIndex: out.source}] = true
+		}
+	}
+	if len(bound) == 0 {
+		return candidates
+	}
+	var result []outputRef
+	for _, ref := range candidates {
+		if !bound[ref] {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// preferDirect leaves out converters if the type is provided by other functions.
+func preferDirect(candidates []outputRef) []outputRef {
+	var direct []outputRef
+	for _, ref := range candidates {
+		if !ref.provider.converter {
+			direct = append(direct, ref)
+		}
+	}
+	if len(direct) == 0 {
+		return candidates
+	}
+	return direct
+}
+
+// clone returns a copy of the parsed function, which is not linked nor called.
+func (f *function) clone() *function {
+	c := *f
+	c.inputs = make([]input, len(f.inputs))
+	for i, in := range f.inputs {
+		c.inputs[i] = input{typ: in.typ, variadic: in.variadic}
+	}
+	c.outputs = append([]output(nil), f.outputs...)
+	if f.cases != nil {
+		c.cases = make(map[any]*function, len(f.cases))
+		for key, fn := range f.cases {
+			c.cases[key] = fn.clone()
+		}
+	}
+	return &c
+}
+
 func (f *function) State() functionState {
 	return f.state
 }
 
-func (f *function) Call(ctx context.Context, logger Logger, dryRun bool) error {
+// callConfig describes how the function is called.
+type callConfig struct {
+	logger       Logger
+	dryRun       bool
+	middleware   CallMiddleware
+	typeTimeouts map[reflect.Type]time.Duration
+	clock        Clock
+	guard        time.Duration // warn about calls running longer
+
+	providerTimeout time.Duration // limits calls of functions other than invokes
+	contextWrappers []ContextWrapper
+}
+
+func (f *function) Call(ctx context.Context, cfg callConfig) error {
 	if f.state >= StateCalled {
 		return nil
 	}
@@ -73,30 +432,94 @@ func (f *function) Call(ctx context.Context, logger Logger, dryRun bool) error {
 		return err
 	}
 
-	if dryRun {
+	if cfg.dryRun && !f.noSkipDryRun {
 		return nil
 	}
+	if cfg.dryRun {
+		for _, arg := range args {
+			if !arg.IsValid() { // some dependency has been skipped
+				cfg.logger.Printf(LogLevelDebug, "dry run: skip %s with skipped dependencies", f.String())
+				return nil
+			}
+		}
+	}
 
+	if f.cases != nil {
+		return f.callCase(args[0])
+	}
+	if cfg.middleware == nil {
+		return f.callWithContext(ctx, args, cfg)
+	}
+	called := false
+	err = cfg.middleware(ctx, func(ctx context.Context) error {
+		called = true
+		return f.callWithContext(ctx, args, cfg)
+	}, f.CallInfo())
+	if err == nil && !called {
+		return fmt.Errorf("%w: call middleware has not called %s", ErrInternalError, f.String())
+	}
+	return err
+}
+
+// callWithContext derives the context of the call with wrappers and timeouts and calls the function.
+func (f *function) callWithContext(ctx context.Context, args []reflect.Value, cfg callConfig) error {
+	if len(cfg.contextWrappers) > 0 {
+		info := f.CallInfo()
+		for _, wrap := range cfg.contextWrappers {
+			ctx = wrap(ctx, info)
+		}
+	}
+	if cfg.providerTimeout > 0 && !f.invoke {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.providerTimeout)
+		defer cancel()
+	}
+	if timeout, ok := f.timeout(cfg.typeTimeouts); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return f.call(ctx, args, cfg)
+}
+
+func (f *function) call(ctx context.Context, args []reflect.Value, cfg callConfig) error {
 	result := make(chan []reflect.Value)
 	var ts int64
 
 	go func() {
-		start := time.Now()
-		values := f.targetFunc.Call(args)
-		sinceStart := time.Since(start)
+		start := cfg.clock.Now()
+		var values []reflect.Value
+		if f.targetFunc.Type().IsVariadic() {
+			values = f.targetFunc.CallSlice(args)
+		} else {
+			values = f.targetFunc.Call(args)
+		}
+		sinceStart := cfg.clock.Now().Sub(start)
 		atomic.StoreInt64(&ts, int64(sinceStart))
 		result <- values
 	}()
 
+	var guard <-chan time.Time
+	if cfg.guard > 0 {
+		timer := time.NewTimer(cfg.guard)
+		defer timer.Stop()
+		guard = timer.C
+	}
+
 	var values []reflect.Value
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case values = <-result:
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-guard:
+			cfg.logger.Printf(LogLevelWarn, "executing %s is still running after %s", f.String(), cfg.guard.String())
+		case values = <-result:
+			done = true
+		}
 	}
 
-	spent := time.Duration(atomic.LoadInt64(&ts))
-	logger.Printf(LogLevelInfo, "executing %s completed in %s", f.String(), spent.String())
+	f.spent = time.Duration(atomic.LoadInt64(&ts))
+	cfg.logger.Printf(LogLevelInfo, "executing %s completed in %s", f.String(), f.spent.String())
 
 	for i, v := range values {
 		if isErrorType(v.Type()) {
@@ -107,41 +530,217 @@ func (f *function) Call(ctx context.Context, logger Logger, dryRun bool) error {
 			continue
 		}
 		f.outputs[i].value = v
+		if f.outputs[i].expiry {
+			f.expires = v.Interface().(time.Time)
+		}
 	}
+	f.bindAsValues()
 
 	return nil
 }
 
-func (f *function) linkInput(typ reflect.Type, provides []*function, assignable typesAssignableFunc) (
-	provider *function, outputIndex int, err error) {
-	for _, provide := range provides {
+// bindAs adds an output of the iface type bound to the first output implementing it.
+func (f *function) bindAs(iface reflect.Type) error {
+	for i, out := range f.outputs {
+		if out.as || isErrorType(out.typ) || !out.typ.Implements(iface) {
+			continue
+		}
+		f.outputs = append(f.outputs, output{typ: iface, as: true, source: i})
+		f.bindAsValues()
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not implement %s", ErrUnsupportedAnnotation, f.String(), iface.String())
+}
+
+func (f *function) bindAsValues() {
+	for i, out := range f.outputs {
+		if out.as {
+			f.outputs[i].value = f.outputs[out.source].value
+		}
+	}
+}
+
+// expired reports whether outputs of the called leased function must not be consumed anymore.
+func (f *function) expired(clock Clock) bool {
+	return f.leased && f.state >= StateCalled && !f.expires.IsZero() && !clock.Now().Before(f.expires)
+}
+
+// timeout returns the shortest timeout configured for the function output types.
+func (f *function) timeout(typeTimeouts map[reflect.Type]time.Duration) (timeout time.Duration, ok bool) {
+	for _, out := range f.outputs {
+		if d, found := typeTimeouts[out.typ]; found && (!ok || d < timeout) {
+			timeout, ok = d, true
+		}
+	}
+	return
+}
+
+func (f *function) CallInfo() CallInfo {
+	info := CallInfo{Name: f.Name()}
+	for _, in := range f.inputs {
+		info.Inputs = append(info.Inputs, in.typ)
+	}
+	for _, out := range f.outputs {
+		info.Outputs = append(info.Outputs, out.typ)
+	}
+	return info
+}
+
+func (f *function) candidates(typ reflect.Type, l linker) []outputRef {
+	var candidates []outputRef
+	for _, provide := range l.provides {
 		if f == provide { // exclude self-providing
 			continue
 		}
+		assignable := l.assignable
+		if provide.supplied && l.strictSupply || provide.synthetic {
+			assignable = typesSimpleAssignable
+		}
 		for outIndex, out := range provide.outputs {
-			if isErrorType(out.typ) { // exclude providing type `error`
+			if !out.injectable() { // exclude providing type `error` and cleanups
 				continue
 			}
 			if !assignable(out.typ, typ) {
 				continue
 			}
-			if provider != nil {
-				return nil, 0,
-					fmt.Errorf("linking: %w of type=%s \nfirst usage:  %s \nsecond usage: %s",
-						ErrMultipleProvide, typ, provider.String(), provide.String(),
-					)
+			candidates = append(candidates, outputRef{provider: provide, outputIndex: outIndex})
+		}
+	}
+	return candidates
+}
+
+func (f *function) indexedCandidates(typ reflect.Type, index outputIndex) []outputRef {
+	var candidates []outputRef
+	for _, ref := range index[typ] {
+		if f == ref.provider { // exclude self-providing
+			continue
+		}
+		candidates = append(candidates, ref)
+	}
+	return candidates
+}
+
+// chooseCandidate returns the candidate with the highest priority,
+// multiple candidates with the same highest priority are an error.
+func (f *function) chooseCandidate(typ reflect.Type, candidates []outputRef, logger Logger) (outputRef, error) {
+	if len(candidates) == 0 {
+		return outputRef{}, nil
+	}
+	best := candidates[0]
+	var tie *outputRef
+	for i := 1; i < len(candidates); i++ {
+		switch priority := candidates[i].provider.priority; {
+		case priority > best.provider.priority:
+			best, tie = candidates[i], nil
+		case priority == best.provider.priority && tie == nil:
+			tie = &candidates[i]
+		}
+	}
+	if tie != nil {
+		return outputRef{}, &LinkError{
+			Type:      typ,
+			Func:      f.String(),
+			Providers: []string{best.String(), tie.String()},
+			Err:       ErrMultipleProvide,
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.provider != best.provider {
+			logger.Printf(LogLevelDebug, "type=%s for func %s: %s (priority %d) shadows %s (priority %d)",
+				typ, f.String(), best.provider.String(), best.provider.priority,
+				candidate.provider.String(), candidate.provider.priority)
+		}
+	}
+	return best, nil
+}
+
+// hints describes provided types which may be confused with the missing type.
+func hints(typ reflect.Type, provides []*function) []string {
+	var result []string
+	for _, provide := range provides {
+		for _, out := range provide.outputs {
+			if out.typ == typ || !out.injectable() {
+				continue
+			}
+			switch {
+			case sameNameOtherPackage(out.typ, typ):
+				result = append(result, fmt.Sprintf("%s from package %q is provided by %s",
+					out.typ, indirect(out.typ).PkgPath(), provide.String()))
+			case sameUnderlying(out.typ, typ):
+				result = append(result, fmt.Sprintf("%s with the same underlying type is provided by %s",
+					out.typ, provide.String()))
 			}
-			provider = provide
-			outputIndex = outIndex
 		}
 	}
-	return
+	return result
+}
+
+// sameUnderlying reports whether types are of the same kind and differ only in names,
+// unlike ConvertibleTo which allows numeric and string conversions.
+func sameUnderlying(t1, t2 reflect.Type) bool {
+	if t1.Kind() != t2.Kind() || t1.Kind() == reflect.Interface {
+		return false
+	}
+	switch t1.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Pointer, reflect.Slice:
+		return t1.Elem() == t2.Elem() && t1.ConvertibleTo(t2)
+	case reflect.Map:
+		return t1.Key() == t2.Key() && t1.Elem() == t2.Elem()
+	}
+	return t1.ConvertibleTo(t2)
+}
+
+// sameNameOtherPackage reports whether types have the same name but are declared in different packages,
+// which usually means that a wrong package has been imported.
+func sameNameOtherPackage(t1, t2 reflect.Type) bool {
+	if t1.Kind() != t2.Kind() {
+		return false
+	}
+	t1, t2 = indirect(t1), indirect(t2)
+	return t1.Name() != "" && t1.Name() == t2.Name() && t1.PkgPath() != t2.PkgPath()
+}
+
+// typeString qualifies generic instantiations with the full package path,
+// since reflect renders only the package name for them.
+func typeString(typ reflect.Type) string {
+	base := indirect(typ)
+	if !strings.Contains(base.Name(), "[") || base.PkgPath() == "" {
+		return typ.String()
+	}
+	return strings.Replace(typ.String(), base.String(), base.PkgPath()+"."+base.Name(), 1)
+}
+
+func indirect(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
 }
 
 func (f *function) collectArgsValues() ([]reflect.Value, error) {
 	var result = make([]reflect.Value, 0, len(f.inputs))
 	for i := range f.inputs {
 		in := f.inputs[i]
+		if in.typ == callerType {
+			caller := f.caller
+			if caller == "" {
+				caller = f.Name()
+			}
+			result = append(result, reflect.ValueOf(Caller(caller)))
+			continue
+		}
+		if in.accessor != nil {
+			result = append(result, accessorValue(in))
+			continue
+		}
+		if in.variadic {
+			values, err := f.collectGroupValues(in)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, values)
+			continue
+		}
 		if in.provider.State() < StateCalled {
 			return nil, fmt.Errorf("%w %s", ErrCyclicProvideDetected, f.String())
 		}
@@ -155,12 +754,74 @@ func (f *function) collectArgsValues() ([]reflect.Value, error) {
 	return result, nil
 }
 
+// accessorValue returns a function resolving the input provider on the first call,
+// later calls return the same value or the same error.
+func accessorValue(in input) reflect.Value {
+	var once sync.Once
+	var err error
+	return reflect.MakeFunc(in.typ, func([]reflect.Value) []reflect.Value {
+		once.Do(func() {
+			err = in.accessor()
+		})
+
+		value := reflect.Zero(in.typ.Out(0))
+		if out := in.provider.outputs[in.outputIndex].value; err == nil && out.IsValid() {
+			value = out
+		}
+		if in.typ.NumOut() == 1 {
+			if err != nil {
+				panic(err)
+			}
+			return []reflect.Value{value}
+		}
+		errValue := reflect.Zero(errorType)
+		if err != nil {
+			errValue = reflect.ValueOf(&err).Elem()
+		}
+		return []reflect.Value{value, errValue}
+	})
+}
+
+// collectGroupValues returns a slice of the variadic input values,
+// the result is invalid if some provider has been skipped by dry run.
+func (f *function) collectGroupValues(in input) (reflect.Value, error) {
+	values := reflect.MakeSlice(in.typ, 0, len(in.group))
+	for _, ref := range in.group {
+		if ref.provider.State() < StateCalled {
+			return reflect.Value{}, fmt.Errorf("%w %s", ErrCyclicProvideDetected, f.String())
+		}
+		value := ref.provider.outputs[ref.outputIndex].value
+		if !value.IsValid() {
+			return reflect.Value{}, nil
+		}
+		values = reflect.Append(values, value)
+	}
+	return values, nil
+}
+
+// looksLikeSupply reports whether a provider without inputs returns a single function,
+// which is usually a function-typed value passed to Provide instead of Supply.
+func (f *function) looksLikeSupply() bool {
+	if f.providesFunc || f.supplied || f.invoke || f.targetFunc.Kind() != reflect.Func {
+		return false
+	}
+	typ := f.targetFunc.Type()
+	return typ.NumIn() == 0 && typ.NumOut() == 1 && typ.Out(0).Kind() == reflect.Func
+}
+
+func (f *function) Name() string {
+	if f.name != "" {
+		return f.name
+	}
+	return funcName(f.targetFunc)
+}
+
 func (f *function) String() string {
 	if f == nil {
 		return "function is nil"
 	}
 
-	name := funcName(f.targetFunc)
+	name := f.Name()
 	defer func() {
 		if err := recover(); err != nil {
 			log.Printf("recovered: %v funcName: %s", err, name)
@@ -169,10 +830,10 @@ func (f *function) String() string {
 
 	var ins, outs []string
 	for _, in := range f.inputs {
-		ins = append(ins, in.typ.String())
+		ins = append(ins, typeString(in.typ))
 	}
 	for _, out := range f.outputs {
-		outs = append(outs, out.typ.String())
+		outs = append(outs, typeString(out.typ))
 	}
 
 	return fmt.Sprintf("%s(%s) (%s)", name, strings.Join(ins, ", "), strings.Join(outs, ", "))
@@ -183,35 +844,50 @@ func (f *function) Debug() string {
 		return "function is nil"
 	}
 
-	name := funcName(f.targetFunc)
+	name := f.Name()
 
 	var ins, outs []string
 	var providers strings.Builder
 	for _, in := range f.inputs {
-		ins = append(ins, in.typ.String())
+		ins = append(ins, typeString(in.typ))
+		if in.variadic {
+			for _, ref := range in.group {
+				providers.WriteRune('\n')
+				providers.WriteString(ref.provider.Name())
+			}
+			continue
+		}
 		if in.provider == nil {
 			providers.WriteString("null")
 			continue
 		}
 		providers.WriteRune('\n')
-		providers.WriteString(funcName(in.provider.targetFunc))
+		providers.WriteString(in.provider.Name())
 	}
 	for _, out := range f.outputs {
-		outs = append(outs, out.typ.String())
+		outs = append(outs, typeString(out.typ))
 	}
 
 	return fmt.Sprintf("%s(%s) (%s) state=%d provides=[%s]",
 		name, strings.Join(ins, ", "), strings.Join(outs, ", "), f.state, providers.String())
 }
 
-func parseSupply(value any) *function {
-	val := reflect.ValueOf(value)
+func parseSupply(value any) (*function, error) {
+	if typ := reflect.TypeOf(value); typ != nil && typ.Implements(errorType) {
+		return nil, fmt.Errorf("%w for %s: errors can't be supplied as dependencies, handle them instead",
+			ErrUnsupportedSupplyTarget, typ.String())
+	}
+	return suppliedValue(reflect.ValueOf(value)), nil
+}
+
+func suppliedValue(val reflect.Value) *function {
 	return &function{
 		outputs: []output{{
 			typ:   val.Type(),
 			value: val,
 		}},
-		state: StateCalled,
+		state:    StateCalled,
+		supplied: true,
 	}
 }
 
@@ -227,6 +903,9 @@ func parseProvide(target any) (*function, error) {
 	for i := 0; i < typ.NumIn(); i++ {
 		inputs[i].typ = typ.In(i)
 	}
+	if typ.IsVariadic() {
+		inputs[len(inputs)-1].variadic = true
+	}
 	for i := 0; i < typ.NumOut(); i++ {
 		outputs[i].typ = typ.Out(i)
 	}
@@ -250,14 +929,291 @@ func parseInvoke(target any) (*function, error) {
 	for i := 0; i < typ.NumIn(); i++ {
 		inputs[i].typ = typ.In(i)
 	}
+	if typ.IsVariadic() {
+		inputs[len(inputs)-1].variadic = true
+	}
+	outputs := make([]output, typ.NumOut())
+	for i := 0; i < typ.NumOut(); i++ {
+		outputs[i].typ = typ.Out(i)
+	}
+
+	return &function{
+		targetFunc: value,
+		inputs:     inputs,
+		outputs:    outputs,
+		state:      StateInitialized,
+		invoke:     true,
+	}, nil
+}
+
+func parsePopulate(target any) (*function, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w for %T: pointer to struct expected", ErrUnsupportedPopulateTarget, target)
+	}
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+	inputs := make([]input, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			return nil, fmt.Errorf("%w for %s: field %s is unexported",
+				ErrUnsupportedPopulateTarget, structType.String(), field.Name)
+		}
+		inputs[i].typ = field.Type
+	}
+
+	in := make([]reflect.Type, len(inputs))
+	for i := range inputs {
+		in[i] = inputs[i].typ
+	}
+	fn := reflect.MakeFunc(reflect.FuncOf(in, nil, false), func(args []reflect.Value) []reflect.Value {
+		for i, arg := range args {
+			structValue.Field(i).Set(arg)
+		}
+		return nil
+	})
+
+	return &function{
+		targetFunc: fn,
+		name:       fmt.Sprintf("PopulateStruct(%s)", value.Type().String()),
+		inputs:     inputs,
+		state:      StateInitialized,
+		invoke:     true,
+	}, nil
+}
+
+func parseConvert(target any) (*function, error) {
+	convert, err := parseProvide(target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedConvertTarget, err)
+	}
+	typ := convert.targetFunc.Type()
+	outs := typ.NumOut()
+	returnsValue := outs >= 1 && outs <= 2 && !isErrorType(typ.Out(0)) && (outs == 1 || isErrorType(typ.Out(1)))
+	if typ.NumIn() != 1 || typ.IsVariadic() || !returnsValue {
+		return nil, fmt.Errorf("%w for %s: func(From) To or func(From) (To, error) expected",
+			ErrUnsupportedConvertTarget, typ.String())
+	}
+	convert.converter = true
+	return convert, nil
+}
 
+// parseLeasedProvide builds a provider of the first output of func() (T, time.Time, error) target,
+// the second output is the expiry.
+func parseLeasedProvide(target any) *function {
+	value := reflect.ValueOf(target)
 	return &function{
 		targetFunc: value,
+		outputs:    []output{{typ: value.Type().Out(0)}, {typ: timeType, expiry: true}, {typ: errorType}},
+		state:      StateInitialized,
+		leased:     true,
+	}
+}
+
+// parsePoolProvide builds a provider of []T calling target, a func(...) T or func(...) (T, error),
+// n() times with the same dependencies.
+func parsePoolProvide(n func() int, target any) (*function, error) {
+	if n == nil {
+		return nil, fmt.Errorf("%w: pool size func is nil", ErrUnsupportedProvideTarget)
+	}
+	pool, err := parseProvide(target)
+	if err != nil {
+		return nil, err
+	}
+	value := pool.targetFunc
+	typ := value.Type()
+	outs := typ.NumOut()
+	if outs < 1 || outs > 2 || isErrorType(typ.Out(0)) || outs == 2 && !isErrorType(typ.Out(1)) {
+		return nil, fmt.Errorf("%w for %s: func(...) T or func(...) (T, error) expected",
+			ErrUnsupportedProvideTarget, typ.String())
+	}
+
+	ins := make([]reflect.Type, typ.NumIn())
+	for i := range ins {
+		ins[i] = typ.In(i)
+	}
+	sliceType := reflect.SliceOf(typ.Out(0))
+	pool.name = fmt.Sprintf("ProvidePool(%s)", funcName(value))
+	pool.outputs = []output{{typ: sliceType}, {typ: errorType}}
+	pool.targetFunc = reflect.MakeFunc(reflect.FuncOf(ins, []reflect.Type{sliceType, errorType}, typ.IsVariadic()),
+		func(args []reflect.Value) []reflect.Value {
+			count := n()
+			if count < 0 {
+				err := fmt.Errorf("%w %d for %s", ErrInvalidPoolSize, count, pool.name)
+				return []reflect.Value{reflect.Zero(sliceType), reflect.ValueOf(&err).Elem()}
+			}
+			values := reflect.MakeSlice(sliceType, 0, count)
+			for i := 0; i < count; i++ {
+				var results []reflect.Value
+				if typ.IsVariadic() {
+					results = value.CallSlice(args)
+				} else {
+					results = value.Call(args)
+				}
+				if len(results) == 2 && !results[1].IsNil() {
+					return []reflect.Value{reflect.Zero(sliceType), results[1]}
+				}
+				values = reflect.Append(values, results[0])
+			}
+			return []reflect.Value{values, reflect.Zero(errorType)}
+		})
+	return pool, nil
+}
+
+// parseWarm builds an invoke consuming the types, given as reflect.Type or pointers like new(T).
+func parseWarm(types []any) (*function, error) {
+	inputs := make([]input, len(types))
+	in := make([]reflect.Type, len(types))
+	names := make([]string, len(types))
+	for i, t := range types {
+		typ, ok := t.(reflect.Type)
+		if !ok {
+			ptr := reflect.TypeOf(t)
+			if ptr == nil || ptr.Kind() != reflect.Pointer {
+				return nil, fmt.Errorf("%w: reflect.Type or pointer like new(T) expected, got %T", ErrUnsupportedWarmTarget, t)
+			}
+			typ = ptr.Elem()
+		}
+		inputs[i].typ, in[i], names[i] = typ, typ, typ.String()
+	}
+
+	fn := reflect.MakeFunc(reflect.FuncOf(in, nil, false), func([]reflect.Value) []reflect.Value {
+		return nil
+	})
+
+	return &function{
+		targetFunc: fn,
+		name:       fmt.Sprintf("Warm(%s)", strings.Join(names, ", ")),
 		inputs:     inputs,
 		state:      StateInitialized,
+		invoke:     true,
 	}, nil
 }
 
+// parseSelect builds a provider which calls one of the cases constructors
+// chosen by the resolved input value. Only dependencies of the chosen case are resolved.
+func parseSelect(selector any, cases map[any]any) (*function, error) {
+	inputPtr := reflect.TypeOf(selector)
+	if inputPtr == nil || inputPtr.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("%w for %T: pointer to input type expected", ErrUnsupportedSelectTarget, selector)
+	}
+	inputType := inputPtr.Elem()
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%w for %s: no cases", ErrUnsupportedSelectTarget, inputType.String())
+	}
+
+	keys := make([]any, 0, len(cases))
+	for key := range cases {
+		if reflect.TypeOf(key) != inputType {
+			return nil, fmt.Errorf("%w for %s: case %v has type %T",
+				ErrUnsupportedSelectTarget, inputType.String(), key, key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	selectCases := make(map[any]*function, len(cases))
+	var outputs []output
+	for _, key := range keys {
+		fn, err := parseProvide(cases[key])
+		if err != nil {
+			return nil, err
+		}
+		fn.noAutoClose = true // outputs are closed as outputs of the select
+		var caseOutputs []output
+		for _, out := range fn.outputs {
+			if !isErrorType(out.typ) {
+				caseOutputs = append(caseOutputs, output{typ: out.typ})
+			}
+		}
+		if outputs == nil {
+			outputs = caseOutputs
+		} else if !sameOutputTypes(outputs, caseOutputs) {
+			return nil, fmt.Errorf("%w for %s: %s outputs differ from other cases",
+				ErrUnsupportedSelectTarget, inputType.String(), fn.String())
+		}
+		selectCases[key] = fn
+	}
+	outputs = append(outputs, output{typ: errorType})
+
+	return &function{
+		name:    fmt.Sprintf("Select(%s)", inputType.String()),
+		inputs:  []input{{typ: inputType}},
+		outputs: outputs,
+		state:   StateInitialized,
+		cases:   selectCases,
+	}, nil
+}
+
+// callCase calls the select case chosen by the selector value, only the chosen case
+// is linked with its dependencies.
+func (f *function) callCase(selector reflect.Value) error {
+	fn, ok := f.cases[selector.Interface()]
+	if !ok {
+		return fmt.Errorf("%w: %v of type %s", ErrNoSelectCase, selector.Interface(), selector.Type().String())
+	}
+	if err := f.resolveCase(fn); err != nil {
+		return err
+	}
+	i := 0
+	for _, out := range fn.outputs {
+		if out.injectable() {
+			f.outputs[i].value = out.value
+			i++
+		}
+	}
+	f.bindAsValues()
+	return nil
+}
+
+func sameOutputTypes(a, b []output) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].typ != b[i].typ {
+			return false
+		}
+	}
+	return true
+}
+
+func parseReflectProvide(typ reflect.Type, factory func() (any, error)) (*function, error) {
+	if typ == nil || factory == nil {
+		return nil, fmt.Errorf("%w: type and factory are required", ErrUnsupportedProvideTarget)
+	}
+	out := []reflect.Type{typ, errorType}
+	targetFunc := reflect.MakeFunc(reflect.FuncOf(nil, out, false), func([]reflect.Value) []reflect.Value {
+		result := reflect.Zero(typ)
+		value, err := factory()
+		switch {
+		case err != nil:
+		case value == nil && isNilValue(result):
+		case value != nil && reflect.TypeOf(value).AssignableTo(typ):
+			result = reflect.ValueOf(value)
+		default:
+			err = fmt.Errorf("%w: %T is not assignable to %s", ErrTypeMismatch, value, typ.String())
+		}
+		return []reflect.Value{result, reflect.ValueOf(&err).Elem()}
+	})
+
+	return &function{
+		targetFunc: targetFunc,
+		name:       fmt.Sprintf("ProvideReflect(%s)", typ.String()),
+		outputs:    []output{{typ: typ}, {typ: errorType}},
+		state:      StateInitialized,
+	}, nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+var cleanupFuncType = reflect.TypeOf((func())(nil))
+
 var loggerType = reflect.TypeOf((*Logger)(nil)).Elem()
 var logFuncType = reflect.TypeOf((*LogFunc)(nil)).Elem()
 
@@ -266,7 +1222,7 @@ func parseLoggerProvide(target any) (*function, error) {
 	typ := value.Type()
 	kind := value.Kind()
 	switch {
-	case kind == reflect.Func && typ.AssignableTo(logFuncType):
+	case kind == reflect.Func && typ.ConvertibleTo(logFuncType):
 		return &function{
 			outputs: []output{{
 				typ:   logFuncType,
@@ -284,6 +1240,9 @@ func parseLoggerProvide(target any) (*function, error) {
 		}, nil
 	case kind != reflect.Func:
 		return nil, fmt.Errorf("%w for %s", ErrUnsupportedLoggerProvider, typ.String())
+	case !returnsLogger(typ):
+		return nil, fmt.Errorf("%w for %s: expected func(rv.LogLevel, string, ...any) or constructor returning %s",
+			ErrUnsupportedLoggerProvider, typ.String(), loggerType.String())
 	}
 
 	inputs := make([]input, typ.NumIn())
@@ -302,6 +1261,15 @@ func parseLoggerProvide(target any) (*function, error) {
 	}, nil
 }
 
+func returnsLogger(typ reflect.Type) bool {
+	for i := 0; i < typ.NumOut(); i++ {
+		if typ.Out(i).Implements(loggerType) {
+			return true
+		}
+	}
+	return false
+}
+
 func funcName(fn reflect.Value) string {
 	if fn.Kind() != reflect.Func {
 		return "noname"
@@ -1,11 +1,19 @@
 package rv
 
+import "sync"
+
 type LogLevel int
 
+// Values of log levels are stable, levels added later get new values instead of renumbering existing ones.
+// Values do not order levels by severity: LogLevelWarn is greater than LogLevelDebug, so a logger
+// filtering by verbosity should switch on levels instead of comparing them, e.g. lvl <= verbosity
+// would treat warnings as the noisiest messages.
 const (
-	LogLevelSilence LogLevel = iota
-	LogLevelInfo
-	LogLevelDebug
+	LogLevelSilence LogLevel = 0
+	LogLevelInfo    LogLevel = 1
+	LogLevelDebug   LogLevel = 2
+	// LogLevelWarn reports suspicious setups and slow calls which do not fail the resolution.
+	LogLevelWarn LogLevel = 3
 )
 
 type Logger interface {
@@ -19,3 +27,38 @@ func (f LogFunc) Printf(lvl LogLevel, format string, args ...any) {
 }
 
 func devNull(_ LogLevel, _ string, _ ...any) {}
+
+// prefixLogger prepends prefix to formats of messages, prefix is escaped for Printf.
+type prefixLogger struct {
+	prefix string
+	logger Logger
+}
+
+func (l prefixLogger) Printf(lvl LogLevel, format string, args ...any) {
+	l.logger.Printf(lvl, l.prefix+format, args...)
+}
+
+// CountingLogger counts messages per level, the zero value is ready to use.
+type CountingLogger struct {
+	mu     sync.Mutex
+	counts map[LogLevel]int
+}
+
+func (l *CountingLogger) Printf(lvl LogLevel, _ string, _ ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[LogLevel]int)
+	}
+	l.counts[lvl]++
+}
+
+func (l *CountingLogger) Counts() map[LogLevel]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[LogLevel]int, len(l.counts))
+	for lvl, count := range l.counts {
+		counts[lvl] = count
+	}
+	return counts
+}
@@ -1,5 +1,12 @@
 package rv
 
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+)
+
 type Option interface {
 	apply(*revolver) error
 }
@@ -8,33 +15,190 @@ func Options(opts ...Option) Option {
 	return optionGroup(opts)
 }
 
+// OptionsFrom applies options produced by factory when options are applied,
+// an error of factory is returned by Revolve.
+func OptionsFrom(factory func() ([]Option, error)) Option {
+	return optionFunc(func(rv *revolver) error {
+		opts, err := factory()
+		if err != nil {
+			return err
+		}
+		return optionGroup(opts).apply(rv)
+	})
+}
+
 func Supply(values ...any) Option {
-	opts := make([]Option, 0, len(values))
-	for _, value := range values {
-		opts = append(opts, supplyOption(value))
+	targets, err := splitAnnotations(values)
+	if err != nil {
+		return errorOption(err)
+	}
+	opts := make([]Option, 0, len(targets))
+	for _, target := range targets {
+		opts = append(opts, supplyOption(target.target, target.annotations...))
 	}
 	return Options(opts...)
 }
 
+// SupplyAs supplies the value under its static type T instead of the dynamic one,
+// useful to supply an interface value without duck typing.
+func SupplyAs[T any](value T) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.provides = append(rv.provides, suppliedValue(reflect.ValueOf(&value).Elem()))
+		return nil
+	})
+}
+
+// SupplyLazy supplies the value returned by fn,
+// fn is called at most once and only if the value is consumed.
+func SupplyLazy[T any](fn func() T) Option {
+	return provideOption(fn)
+}
+
+// ProvideLeased provides the value returned by fn until the returned expiry, fn is called again
+// when the value is injected after expiry. Expiry is checked with the clock set by WithClock.
+func ProvideLeased[T any](fn func() (T, time.Time, error)) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.provides = append(rv.provides, parseLeasedProvide(fn))
+		return nil
+	})
+}
+
+// ProvidePool provides []T of n() values constructed by fn, a func(...) T or func(...) (T, error).
+// All values share the same dependencies, n is called once when the slice is consumed.
+// A negative n() fails the resolution with ErrInvalidPoolSize.
+func ProvidePool(n func() int, fn any) Option {
+	return optionFunc(func(rv *revolver) error {
+		pool, err := parsePoolProvide(n, fn)
+		if err != nil {
+			return err
+		}
+		rv.provides = append(rv.provides, pool)
+		return nil
+	})
+}
+
+// Provide registers constructors called when their outputs are consumed. A parameter of type func() T
+// or func() (T, error) which is not provided directly resolves T on demand: T is constructed by the first
+// call and later calls return the same value. If T cannot be resolved, func() (T, error) returns the error
+// and func() T panics with it. Accessors must not be called concurrently with Revolve.
+// A variadic parameter receives all provided values of its element type, as for Invoke.
 func Provide(funcs ...any) Option {
-	opts := make([]Option, 0, len(funcs))
-	for _, fn := range funcs {
-		opts = append(opts, provideOption(fn))
+	targets, err := splitAnnotations(funcs)
+	if err != nil {
+		return errorOption(err)
+	}
+	opts := make([]Option, 0, len(targets))
+	for _, target := range targets {
+		opts = append(opts, provideOption(target.target, target.annotations...))
 	}
 	return Options(opts...)
 }
 
+// ProvideReflect provides a value of the runtime type typ built by factory.
+func ProvideReflect(typ reflect.Type, factory func() (any, error)) Option {
+	return optionFunc(func(rv *revolver) error {
+		provide, err := parseReflectProvide(typ, factory)
+		if err != nil {
+			return err
+		}
+		rv.provides = append(rv.provides, provide)
+		return nil
+	})
+}
+
+// Convert registers converter, a func(From) To or func(From) (To, error), which provides To
+// only if no other function provides it. Converters are chained when From is converted as well.
+func Convert(converter any) Option {
+	return optionFunc(func(rv *revolver) error {
+		convert, err := parseConvert(converter)
+		if err != nil {
+			return err
+		}
+		rv.provides = append(rv.provides, convert)
+		return nil
+	})
+}
+
+func Select(input any, cases map[any]any) Option {
+	return optionFunc(func(rv *revolver) error {
+		provide, err := parseSelect(input, cases)
+		if err != nil {
+			return err
+		}
+		rv.provides = append(rv.provides, provide)
+		return nil
+	})
+}
+
+// Invoke calls funcs with their dependencies. A variadic parameter receives all provided values
+// of its element type ordered by Priority, from the highest, and by registration for equal priorities.
 func Invoke(funcs ...any) Option {
+	targets, err := splitAnnotations(funcs)
+	if err != nil {
+		return errorOption(err)
+	}
 	var opts []Option
-	for _, fn := range funcs {
-		opts = append(opts, invokeOption(fn))
+	for _, target := range targets {
+		opts = append(opts, invokeOption(target.target, target.annotations...))
 	}
 	return Options(opts...)
 }
 
+func PopulateStruct(target any) Option {
+	return optionFunc(func(rv *revolver) error {
+		invoke, err := parsePopulate(target)
+		if err != nil {
+			return err
+		}
+		rv.invokes = append(rv.invokes, invoke)
+		return nil
+	})
+}
+
+// Warm constructs the types eagerly, like an invoke consuming them. Types are given
+// as reflect.Type or pointers like new(T).
+func Warm(types ...any) Option {
+	return optionFunc(func(rv *revolver) error {
+		invoke, err := parseWarm(types)
+		if err != nil {
+			return err
+		}
+		rv.invokes = append(rv.invokes, invoke)
+		return nil
+	})
+}
+
 func WithDuckTyping() Option {
 	return optionFunc(func(rv *revolver) error {
 		rv.assignable = duckTypingAssignable
+		rv.duckTyping = true
+		return nil
+	})
+}
+
+// WithStrictSupply matches supplied values by their exact type even with duck typing,
+// use As or SupplyAs to supply values under interface types.
+func WithStrictSupply() Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.strictSupply = true
+		return nil
+	})
+}
+
+// WithUnambiguousInterfaces fails with ErrAmbiguousInterface if an interface input anywhere in the graph
+// is matched by more than one provider, so that adding a second implementation doesn't break linking later.
+func WithUnambiguousInterfaces() Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.unambiguous = true
+		return nil
+	})
+}
+
+// WithNoUnusedSupplies fails with ErrUnusedSupply if a supplied value is not consumed after linking.
+// Unused constructors are not reported, since they are never called.
+func WithNoUnusedSupplies() Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.noUnusedSupplies = true
 		return nil
 	})
 }
@@ -46,6 +210,123 @@ func WithDryRun() Option {
 	})
 }
 
+func WithRequireInvoke() Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.requireInvoke = true
+		return nil
+	})
+}
+
+// CallInfo describes a called provide or invoke function.
+type CallInfo struct {
+	Name    string
+	Inputs  []reflect.Type
+	Outputs []reflect.Type
+}
+
+// CallMiddleware wraps the call of every provide and invoke function. ctx is the resolution context,
+// next performs the call with the given context and must be called exactly once. Context wrappers
+// and timeouts derive the context of the call from the one passed to next.
+type CallMiddleware func(ctx context.Context, next func(ctx context.Context) error, info CallInfo) error
+
+func WithCallMiddleware(middleware CallMiddleware) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.middleware = middleware
+		return nil
+	})
+}
+
+// ContextWrapper derives the context of a single call, e.g. with a tighter deadline.
+type ContextWrapper func(ctx context.Context, info CallInfo) context.Context
+
+// WithContextWrapper wraps the context of every provide and invoke call, the wrapped context
+// is used for that call only. Wrappers of multiple options are applied in the order of options.
+func WithContextWrapper(wrapper ContextWrapper) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.contextWrappers = append(rv.contextWrappers, wrapper)
+		return nil
+	})
+}
+
+// WithTypeTimeout limits the construction time of providers returning the given types.
+func WithTypeTimeout(timeouts map[reflect.Type]time.Duration) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.typeTimeouts = timeouts
+		return nil
+	})
+}
+
+// WithFlameOutput writes durations of called functions to w
+// in the folded stack format suitable for flamegraph.pl.
+func WithFlameOutput(w io.Writer) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.flame = w
+		return nil
+	})
+}
+
+func WithClock(clock Clock) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.clock = clock
+		return nil
+	})
+}
+
+// WithProviderTimeout limits the call time of every constructor, invokes are limited by ctx only.
+func WithProviderTimeout(timeout time.Duration) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.providerTimeout = timeout
+		return nil
+	})
+}
+
+// WithCallGuard warns about functions which are still running after the threshold,
+// the call itself is not interrupted.
+func WithCallGuard(threshold time.Duration) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.callGuard = threshold
+		return nil
+	})
+}
+
+// WithOnInvoke calls fn after each invoke has finished, err is nil if the invoke succeeded.
+func WithOnInvoke(fn func(name string, err error)) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.onInvoke = fn
+		return nil
+	})
+}
+
+// WithAutoClose closes provided values implementing io.Closer
+// in reverse construction order when the context is done.
+func WithAutoClose() Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.autoClose = true
+		return nil
+	})
+}
+
+type ProgressFunc func(done, total int)
+
+// WithProgress reports the number of called functions registered with options, (total, total)
+// is reported when the resolution completes even if some providers were never consumed.
+func WithProgress(fn ProgressFunc) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.progress = fn
+		return nil
+	})
+}
+
+// WithLogPrefix prepends prefix, like "[rv] ", to every message passed to the logger.
+func WithLogPrefix(prefix string) Option {
+	return optionFunc(func(rv *revolver) error {
+		rv.logPrefix = prefix
+		return nil
+	})
+}
+
+// WithLogger sets the logger used by rv. Target may be a Logger, any function
+// with the LogFunc signature or a constructor returning a Logger.
 func WithLogger(target any) Option {
 	return optionFunc(func(rv *revolver) error {
 		provide, err := parseLoggerProvide(target)
@@ -78,31 +359,58 @@ func (of optionFunc) apply(rv *revolver) error {
 	return of(rv)
 }
 
-func supplyOption(value any) optionFunc {
+func supplyOption(value any, annotations ...Annotation) optionFunc {
 	return func(rv *revolver) error {
-		rv.provides = append(rv.provides, parseSupply(value))
+		supply, err := parseSupply(value)
+		if err != nil {
+			return err
+		}
+		if err = applyAnnotations(supply, annotations); err != nil {
+			return err
+		}
+		rv.provides = append(rv.provides, supply)
 		return nil
 	}
 }
 
-func provideOption(target any) optionFunc {
+func errorOption(err error) optionFunc {
+	return func(*revolver) error {
+		return err
+	}
+}
+
+func provideOption(target any, annotations ...Annotation) optionFunc {
 	return func(rv *revolver) error {
 		provide, err := parseProvide(target)
 		if err != nil {
 			return err
 		}
+		if err = applyAnnotations(provide, annotations); err != nil {
+			return err
+		}
+		if provide.cleanupOnly() {
+			provide.invoke = true
+			rv.invokes = append(rv.invokes, provide)
+			return nil
+		}
 		rv.provides = append(rv.provides, provide)
 		return nil
 	}
 }
 
-func invokeOption(target any) optionFunc {
+func invokeOption(target any, annotations ...Annotation) optionFunc {
 	return func(rv *revolver) error {
 		invoke, err := parseInvoke(target)
 		if err != nil {
 			return err
 		}
+		if err = applyAnnotations(invoke, annotations); err != nil {
+			return err
+		}
 		rv.invokes = append(rv.invokes, invoke)
+		if invoke.feeds {
+			rv.provides = append(rv.provides, invoke)
+		}
 		return nil
 	}
 }
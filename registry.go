@@ -0,0 +1,48 @@
+package rv
+
+import "reflect"
+
+type Registry interface {
+	Types() []reflect.Type
+	Has(typ reflect.Type) bool
+}
+
+var registryType = reflect.TypeOf((*Registry)(nil)).Elem()
+
+type registry struct {
+	rv *revolver
+}
+
+func (r registry) Types() []reflect.Type {
+	var types []reflect.Type
+	for _, provide := range r.rv.provides {
+		for _, out := range provide.outputs {
+			if !out.injectable() {
+				continue
+			}
+			types = append(types, out.typ)
+		}
+	}
+	return types
+}
+
+func (r registry) Has(typ reflect.Type) bool {
+	for _, t := range r.Types() {
+		if r.rv.assignable(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRegistrySupply(rv *revolver) *function {
+	return &function{
+		name:      "Registry",
+		synthetic: true,
+		outputs: []output{{
+			typ:   registryType,
+			value: reflect.ValueOf(registry{rv: rv}),
+		}},
+		state: StateCalled,
+	}
+}
@@ -4,23 +4,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
+	"time"
 )
 
 var (
+	ErrUnsupportedSupplyTarget   = errors.New("unsupported supply target")
 	ErrUnsupportedProvideTarget  = errors.New("unsupported provide target")
 	ErrUnsupportedLoggerProvider = errors.New("unsupported logger provider")
 	ErrUnsupportedInvokeTarget   = errors.New("unsupported invoke target")
+	ErrUnsupportedAnnotation     = errors.New("unsupported annotation")
+	ErrUnsupportedPopulateTarget = errors.New("unsupported populate target")
+	ErrUnsupportedSelectTarget   = errors.New("unsupported select target")
+	ErrUnsupportedWarmTarget     = errors.New("unsupported warm target")
+	ErrUnsupportedConvertTarget  = errors.New("unsupported convert target")
 	ErrMultipleProvide           = errors.New("multiple provide")
+	ErrAmbiguousInterface        = errors.New("ambiguous interface")
 	ErrCannotProvideValue        = errors.New("cannot provide value")
 	ErrCyclicProvideDetected     = errors.New("cyclic provide detected")
+	ErrSelfDependency            = errors.New("self dependency")
+	ErrNoSelectCase              = errors.New("no select case")
+	ErrNoInvoke                  = errors.New("no invoke")
+	ErrUnusedSupply              = errors.New("unused supply")
+	ErrConfigDecode              = errors.New("config decode")
+	ErrTypeMismatch              = errors.New("type mismatch")
+	ErrInvalidPoolSize           = errors.New("invalid pool size")
 	ErrInternalError             = errors.New("internal error")
 )
 
+type LinkError struct {
+	Type      reflect.Type
+	Func      string
+	Providers []string
+	Hints     []string
+	Err       error
+}
+
+func (e *LinkError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "linking: %s type=%s for func %s", e.Err, e.Type, e.Func)
+	for _, provider := range e.Providers {
+		fmt.Fprintf(&b, " \nprovided by: %s", provider)
+	}
+	for _, hint := range e.Hints {
+		fmt.Fprintf(&b, " \nhint: %s", hint)
+	}
+	return b.String()
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
 func Revolve(ctx context.Context, opts ...Option) error {
 	rv := &revolver{
 		logger:     LogFunc(devNull),
 		assignable: typesSimpleAssignable,
+		clock:      systemClock{},
 	}
 	for _, opt := range opts {
 		if opt == nil {
@@ -30,10 +72,18 @@ func Revolve(ctx context.Context, opts ...Option) error {
 			return err
 		}
 	}
+	rv.provides = append(rv.provides, parseRegistrySupply(rv))
+	rv.errorSink = errorSink{errs: make(chan error, 1)}
+	rv.errorSinkSupply = parseErrorSinkSupply(rv.errorSink)
+	rv.provides = append(rv.provides, rv.errorSinkSupply)
+	rv.initProgress()
 
 	if err := rv.resolveLogger(ctx); err != nil {
 		return err
 	}
+	if rv.logPrefix != "" {
+		rv.logger = prefixLogger{prefix: strings.ReplaceAll(rv.logPrefix, "%", "%%"), logger: rv.logger}
+	}
 
 	rv.logger.Printf(LogLevelInfo, "all options have been applied")
 
@@ -43,26 +93,78 @@ func Revolve(ctx context.Context, opts ...Option) error {
 	default:
 	}
 
-	return rv.resolve(ctx)
+	err := rv.resolve(ctx)
+	if err != nil {
+		rv.logUnreachedInvokes()
+	}
+	rv.closeOnDone(ctx)
+	return err
 }
 
 type revolver struct {
-	logger        Logger
-	loggerInvoker *function
-	assignable    typesAssignableFunc
-	dryRun        bool
+	logger           Logger
+	logPrefix        string
+	loggerInvoker    *function
+	assignable       typesAssignableFunc
+	duckTyping       bool
+	strictSupply     bool
+	dryRun           bool
+	requireInvoke    bool
+	autoClose        bool
+	progress         ProgressFunc
+	middleware       CallMiddleware
+	typeTimeouts     map[reflect.Type]time.Duration
+	flame            io.Writer
+	clock            Clock
+	callGuard        time.Duration
+	providerTimeout  time.Duration
+	contextWrappers  []ContextWrapper
+	unambiguous      bool
+	noUnusedSupplies bool
+	onInvoke         func(name string, err error)
 
 	provides []*function // provide functions instances
 	invokes  []*function // invoke functions instances
+	index    outputIndex // provided outputs by exact type, nil with duck typing
+
+	clones          []*function        // per consumer clones of providers requesting Caller
+	progressFuncs   map[*function]bool // functions registered by the user, counted by progress
+	progressDone    int
+	closers         []io.Closer // closed in reverse order when the context is done
+	errorSink       errorSink
+	errorSinkSupply *function
 }
 
 func (rv *revolver) resolve(ctx context.Context) error {
+	start := rv.clock.Now()
+	if _, ok := ctx.Deadline(); !ok && rv.providerTimeout <= 0 {
+		rv.logger.Printf(LogLevelWarn, "context has no deadline, a hanging constructor will block forever: "+
+			"consider using context.WithTimeout")
+	}
 	if rv.dryRun {
 		rv.logger.Printf(LogLevelInfo, "dry run mode")
 	}
+	if rv.requireInvoke && len(rv.invokes) == 0 {
+		return ErrNoInvoke
+	}
 
 	for _, p := range rv.provides {
 		rv.logger.Printf(LogLevelInfo, "provide %s", p.String())
+		if p.looksLikeSupply() {
+			rv.logger.Printf(LogLevelWarn, "provide %s returns a function without inputs: "+
+				"use Supply for function values or annotate it with ProvidesFunc", p.String())
+		}
+	}
+
+	rv.warnShadowedSupplies()
+
+	if !rv.duckTyping {
+		rv.index = newOutputIndex(rv.provides)
+	}
+	if rv.unambiguous {
+		if err := rv.checkUnambiguousInterfaces(ctx); err != nil {
+			return err
+		}
 	}
 
 	for _, fn := range rv.invokes {
@@ -71,28 +173,83 @@ func (rv *revolver) resolve(ctx context.Context) error {
 			return ctx.Err()
 		default:
 		}
-		provides, err := fn.LinkProvides(rv.provides, rv.assignable)
+		provides, err := fn.LinkProvides(rv.linker(ctx, rv.assignable))
 		if err != nil {
 			return err
 		}
-		err = rv.dfs(ctx, provides, rv.assignable, 1)
+		err = rv.dfs(ctx, provides, rv.assignable, []string{fn.Name()})
 		if err != nil {
 			return err
 		}
 	}
 
 	rv.logger.Printf(LogLevelInfo, "all provides have been linked")
+	if rv.noUnusedSupplies {
+		if err := rv.checkUnusedSupplies(ctx); err != nil {
+			return err
+		}
+	}
 
 	for _, fn := range rv.invokes {
-		err := fn.Call(ctx, rv.logger, rv.dryRun)
+		err := rv.call(ctx, fn, nil)
+		if rv.onInvoke != nil {
+			rv.onInvoke(fn.Name(), err)
+		}
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	rv.finishProgress()
+	rv.logSummary(rv.clock.Now().Sub(start))
+	return rv.waitErrorSink(ctx)
 }
 
-func (rv *revolver) dfs(ctx context.Context, funcs []*function, assignable typesAssignableFunc, depth int) error {
+func (rv *revolver) logSummary(spent time.Duration) {
+	var provides, supplies int
+	for _, p := range rv.provides {
+		switch {
+		case p.supplied:
+			supplies++
+		case (p.targetFunc.IsValid() || p.cases != nil) && !p.feeds:
+			provides++
+		}
+	}
+	mode := ""
+	if rv.dryRun {
+		mode = " (dry run)"
+	}
+	rv.logger.Printf(LogLevelInfo, "resolved %d provides, %d supplies, %d invokes in %s%s",
+		provides, supplies, len(rv.invokes), spent, mode)
+}
+
+func (rv *revolver) waitErrorSink(ctx context.Context) error {
+	if rv.dryRun || !rv.errorSinkSupply.consumed {
+		return nil
+	}
+	rv.logger.Printf(LogLevelInfo, "waiting for reported errors until the context is done")
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-rv.errorSink.errs:
+		return err
+	}
+}
+
+func (rv *revolver) logUnreachedInvokes() {
+	var unreached []string
+	for _, fn := range rv.invokes {
+		if fn.State() < StateCalled {
+			unreached = append(unreached, fn.String())
+		}
+	}
+	if len(unreached) > 0 {
+		rv.logger.Printf(LogLevelWarn, "invokes have not been reached: \n%s", strings.Join(unreached, "\n"))
+	}
+}
+
+// dfs links and calls funcs with their providers, path contains names of dependent functions.
+func (rv *revolver) dfs(ctx context.Context, funcs []*function, assignable typesAssignableFunc, path []string) error {
+	depth := len(path)
 	for _, fn := range funcs {
 		select {
 		case <-ctx.Done():
@@ -101,11 +258,11 @@ func (rv *revolver) dfs(ctx context.Context, funcs []*function, assignable types
 		}
 		if fn.State() == StateInitialized {
 			rv.logger.Printf(LogLevelDebug, "[%d] link provides: %s ", depth, fn.Debug())
-			providers, err := fn.LinkProvides(rv.provides, assignable)
+			providers, err := fn.LinkProvides(rv.linker(ctx, assignable))
 			if err != nil {
 				return err
 			}
-			err = rv.dfs(ctx, providers, assignable, depth+1)
+			err = rv.dfs(ctx, providers, assignable, appendPath(path, fn.Name()))
 			if err != nil {
 				if errors.Is(err, ErrCyclicProvideDetected) {
 					err = fmt.Errorf("%w -> %s", err, fn.String())
@@ -114,18 +271,292 @@ func (rv *revolver) dfs(ctx context.Context, funcs []*function, assignable types
 			}
 		}
 		rv.logger.Printf(LogLevelDebug, "[%d] call: %s", depth, fn.Debug())
-		if err := fn.Call(ctx, rv.logger, rv.dryRun); err != nil {
+		if err := rv.call(ctx, fn, path); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// warnShadowedSupplies warns about constructors providing the same type as supplied values,
+// which is usually an unintended composition of modules.
+func (rv *revolver) warnShadowedSupplies() {
+	for _, supply := range rv.provides {
+		if !supply.supplied {
+			continue
+		}
+		for _, out := range supply.outputs {
+			if !out.injectable() {
+				continue
+			}
+			for _, provide := range rv.provides {
+				if provide.supplied || provide.converter || !provide.provides(out.typ, linker{assignable: typesSimpleAssignable}) {
+					continue
+				}
+				rv.logger.Printf(LogLevelWarn, "type=%s is both supplied and provided by %s", out.typ, provide.String())
+			}
+		}
+	}
+}
+
+// checkUnusedSupplies fails if some supplied value is not reached from invokes, including supplies
+// consumed by select cases which are linked only when chosen.
+func (rv *revolver) checkUnusedSupplies(ctx context.Context) error {
+	l := rv.linker(ctx, rv.assignable)
+	l.logger = LogFunc(devNull)
+	reached := make(map[*function]bool)
+	for _, fn := range rv.invokes {
+		fn.reach(l, reached)
+	}
+	if rv.loggerInvoker != nil {
+		rv.loggerInvoker.reach(l, reached)
+	}
+	var unused []string
+	for _, provide := range rv.provides {
+		if provide.supplied && !reached[provide] {
+			unused = append(unused, provide.String())
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnusedSupply, strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// checkUnambiguousInterfaces fails if an interface input of any function matches outputs of several
+// providers, even when priorities would choose one of them.
+func (rv *revolver) checkUnambiguousInterfaces(ctx context.Context) error {
+	l := rv.linker(ctx, rv.assignable)
+	for _, fn := range rv.functions() {
+		for _, in := range fn.inputs {
+			if in.variadic || in.typ.Kind() != reflect.Interface {
+				continue
+			}
+			var providers []string
+			seen := make(map[*function]bool)
+			for _, ref := range fn.linkCandidates(in.typ, l) {
+				if ref.provider.converter || seen[ref.provider] {
+					continue
+				}
+				seen[ref.provider] = true
+				providers = append(providers, ref.provider.String())
+			}
+			if len(providers) > 1 {
+				return &LinkError{
+					Type:      in.typ,
+					Func:      fn.String(),
+					Providers: providers,
+					Err:       ErrAmbiguousInterface,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func appendPath(path []string, name string) []string {
+	result := make([]string, 0, len(path)+1)
+	result = append(result, path...)
+	return append(result, name)
+}
+
+func (rv *revolver) linker(ctx context.Context, assignable typesAssignableFunc) linker {
+	return linker{
+		provides:     rv.provides,
+		index:        rv.index,
+		assignable:   assignable,
+		strictSupply: rv.strictSupply,
+		logger:       rv.logger,
+		resolve: func(provider *function, path []string) error {
+			return rv.dfs(ctx, []*function{provider}, assignable, path)
+		},
+		register: rv.registerClone,
+	}
+}
+
+func (rv *revolver) call(ctx context.Context, fn *function, path []string) error {
+	if err := rv.renewLeases(ctx, fn, path); err != nil {
+		return err
+	}
+	called := fn.State() >= StateCalled
+	renewed := fn.expired(rv.clock)
+	if renewed {
+		rv.logger.Printf(LogLevelInfo, "lease of %s has expired", fn.String())
+		fn.state = StateLinked
+	}
+	if err := fn.Call(ctx, rv.callConfig()); err != nil {
+		return err
+	}
+	if !called {
+		rv.reportProgress(fn)
+	}
+	if !called || renewed {
+		rv.trackClosers(fn)
+		rv.writeFlame(fn, path)
+	}
+	return nil
+}
+
+// renewLeases calls again expired leased providers of the function before it consumes their outputs.
+func (rv *revolver) renewLeases(ctx context.Context, fn *function, path []string) error {
+	if fn.State() >= StateCalled {
+		return nil
+	}
+	for _, in := range fn.inputs {
+		providers := make([]*function, 0, len(in.group)+1)
+		if in.provider != nil && in.accessor == nil {
+			providers = append(providers, in.provider)
+		}
+		for _, ref := range in.group {
+			providers = append(providers, ref.provider)
+		}
+		for _, provider := range providers {
+			if !provider.expired(rv.clock) {
+				continue
+			}
+			if err := rv.call(ctx, provider, appendPath(path, fn.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFlame writes the call duration in the folded stack format.
+func (rv *revolver) writeFlame(fn *function, path []string) {
+	if rv.flame == nil || rv.dryRun || !fn.targetFunc.IsValid() {
+		return
+	}
+	frames := make([]string, 0, len(path)+2)
+	frames = append(frames, "root")
+	for _, name := range appendPath(path, fn.Name()) {
+		frames = append(frames, flameFrameReplacer.Replace(name))
+	}
+	ms := float64(fn.spent) / float64(time.Millisecond)
+	if _, err := fmt.Fprintf(rv.flame, "%s %.3f\n", strings.Join(frames, ";"), ms); err != nil {
+		rv.logger.Printf(LogLevelWarn, "write flame output: %v", err)
+	}
+}
+
+var flameFrameReplacer = strings.NewReplacer(";", "_", " ", "_")
+
+func (rv *revolver) trackClosers(fn *function) {
+	for _, out := range fn.outputs {
+		if !out.value.IsValid() || isNilValue(out.value) {
+			continue
+		}
+		if out.cleanup {
+			rv.closers = append(rv.closers, cleanupCloser(out.value.Interface().(func())))
+			continue
+		}
+		if !rv.autoClose || fn.noAutoClose || out.as { // As outputs hold the value of their source output
+			continue
+		}
+		if closer, ok := out.value.Interface().(io.Closer); ok {
+			rv.closers = append(rv.closers, closer)
+		}
+	}
+}
+
+// cleanupCloser runs a function registered by Cleanup together with auto closed values.
+type cleanupCloser func()
+
+func (c cleanupCloser) Close() error {
+	c()
+	return nil
+}
+
+func (rv *revolver) closeOnDone(ctx context.Context) {
+	if len(rv.closers) == 0 {
+		return
+	}
+	closers := rv.closers
+	go func() {
+		<-ctx.Done()
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].Close(); err != nil {
+				rv.logger.Printf(LogLevelWarn, "auto close %T: %v", closers[i], err)
+			}
+		}
+	}()
+}
+
+func (rv *revolver) callConfig() callConfig {
+	return callConfig{
+		logger:       rv.logger,
+		dryRun:       rv.dryRun,
+		middleware:   rv.middleware,
+		typeTimeouts: rv.typeTimeouts,
+		clock:        rv.clock,
+		guard:        rv.callGuard,
+
+		providerTimeout: rv.providerTimeout,
+		contextWrappers: rv.contextWrappers,
+	}
+}
+
+// initProgress collects the functions counted by progress: feeding invokes are counted once,
+// synthetic supplies like Registry and the invoke setting the logger are not counted.
+func (rv *revolver) initProgress() {
+	if rv.progress == nil {
+		return
+	}
+	rv.progressFuncs = make(map[*function]bool)
+	for _, fn := range rv.functions() {
+		if fn.synthetic || fn == rv.loggerInvoker || rv.progressFuncs[fn] {
+			continue
+		}
+		rv.progressFuncs[fn] = true
+		if fn.State() >= StateCalled {
+			rv.progressDone++
+		}
+	}
+}
+
+func (rv *revolver) reportProgress(fn *function) {
+	if rv.progress == nil || !rv.progressFuncs[fn] {
+		return
+	}
+	rv.progressDone++
+	rv.progress(rv.progressDone, len(rv.progressFuncs))
+}
+
+// finishProgress reports the completion, providers which were never consumed are not called.
+func (rv *revolver) finishProgress() {
+	if rv.progress == nil || rv.progressDone == len(rv.progressFuncs) {
+		return
+	}
+	rv.progressDone = len(rv.progressFuncs)
+	rv.progress(rv.progressDone, rv.progressDone)
+}
+
+// registerClone tracks the per consumer clone of the provider, progress counts clones instead
+// of the provider since it is never called itself.
+func (rv *revolver) registerClone(provider, clone *function) {
+	rv.clones = append(rv.clones, clone)
+	if rv.progressFuncs == nil {
+		return
+	}
+	delete(rv.progressFuncs, provider)
+	rv.progressFuncs[clone] = true
+}
+
+func (rv *revolver) functions() []*function {
+	funcs := make([]*function, 0, len(rv.provides)+len(rv.invokes)+len(rv.clones)+1)
+	funcs = append(funcs, rv.provides...)
+	funcs = append(funcs, rv.invokes...)
+	funcs = append(funcs, rv.clones...)
+	if rv.loggerInvoker != nil {
+		funcs = append(funcs, rv.loggerInvoker)
+	}
+	return funcs
+}
+
 func (rv *revolver) resolveLogger(ctx context.Context) error {
 	if rv.loggerInvoker == nil {
 		return nil
 	}
-	return rv.dfs(ctx, []*function{rv.loggerInvoker}, duckTypingAssignable, 1)
+	return rv.dfs(ctx, []*function{rv.loggerInvoker}, duckTypingAssignable, []string{"WithLogger"})
 }
 
 type typesAssignableFunc func(t1, t2 reflect.Type) bool
@@ -138,6 +569,14 @@ func duckTypingAssignable(t1, t2 reflect.Type) bool {
 	return t1 == t2 || t1.AssignableTo(t2) || t2.AssignableTo(t1)
 }
 
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
 func isErrorType(v reflect.Type) bool {
-	return v.Kind() == reflect.Interface && v.String() == "error"
+	return v == errorType
 }
@@ -1,9 +1,15 @@
 package rv
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -472,78 +478,1956 @@ func TestRevolve(t *testing.T) {
 			error:               ErrCyclicProvideDetected,
 			invokeMustBeSkipped: true,
 		},
+		{
+			name: "registry",
+			option: Options(
+				Supply(&Foo{}),
+				Invoke(func(registry Registry) {
+					if !registry.Has(reflect.TypeOf(&Foo{})) {
+						panic("registry must have *Foo")
+					}
+					if registry.Has(reflect.TypeOf(&Bar{})) {
+						panic("registry must not have *Bar")
+					}
+				}),
+			),
+		},
+		{
+			name: "dry run no skip",
+			option: Options(
+				WithDryRun(),
+				Provide(func() *Foo { return &Foo{} }, NoSkipDryRun()),
+				Provide(func(foo *Foo) *Bar {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+					return &Bar{}
+				}, NoSkipDryRun()),
+				Provide(func() *Buzz { return &Buzz{} }),
+				Provide(func(buzz *Buzz) *FooBar {
+					panic("it must not be called")
+				}, NoSkipDryRun()),
+				Invoke(func(bar *Bar, fooBar *FooBar) {}),
+			),
+			invokeMustBeSkipped: true,
+		},
+		{
+			name:   "annotation without target",
+			option: Provide(NoSkipDryRun()),
+			error:  ErrUnsupportedAnnotation,
+		},
+		{
+			name:   "populate struct unsupported",
+			option: PopulateStruct(Foo{}),
+			error:  ErrUnsupportedPopulateTarget,
+		},
+		{
+			name: "populate struct unexported field",
+			option: PopulateStruct(&struct {
+				foo *Foo
+			}{}),
+			error: ErrUnsupportedPopulateTarget,
+		},
+		{
+			name: "populate struct unsatisfiable field",
+			option: PopulateStruct(&struct {
+				Foo *Foo
+			}{}),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "select",
+			option: Options(
+				Supply(testEnv("prod")),
+				Supply(&Bar{}),
+				Select(new(testEnv), map[any]any{
+					testEnv("dev"):  func() (IFoo, error) { panic("it must not be called") },
+					testEnv("prod"): func(bar *Bar) IFoo { return &FooBar{} },
+				}),
+				Invoke(func(foo IFoo) {
+					if _, ok := foo.(*FooBar); !ok {
+						panic("foo must be *FooBar")
+					}
+				}),
+			),
+		},
+		{
+			name: "select unmatched",
+			option: Options(
+				Supply(testEnv("test")),
+				Select(new(testEnv), map[any]any{
+					testEnv("prod"): func() IFoo { return &FooBar{} },
+				}),
+				Invoke(func(foo IFoo) {}),
+			),
+			error:               ErrNoSelectCase,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "select different outputs",
+			option: Select(new(testEnv), map[any]any{
+				testEnv("dev"):  func() IFoo { return &FooBar{} },
+				testEnv("prod"): func() IBar { return &FooBar{} },
+			}),
+			error:               ErrUnsupportedSelectTarget,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "supply multiple",
+			option: Options(
+				Supply(&Foo{}, &Foo{}),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               ErrMultipleProvide,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "supply missing",
+			option: Options(
+				Supply(&Foo{}),
+				Invoke(func(foo *Foo, bar *Bar) {}),
+			),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "with named log func type",
+			option: Options(
+				Provide(func() *Foo { return &Foo{} }),
+				Invoke(func(foo *Foo) {}),
+				WithLogger(namedLogFunc(customLogFunc)),
+			),
+		},
+		{
+			name: "with log func wrong signature",
+			option: Options(
+				Provide(func() *Foo { return &Foo{} }),
+				Invoke(func(foo *Foo) {}),
+				WithLogger(func(format string, args ...any) {}),
+			),
+			error:               ErrUnsupportedLoggerProvider,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "with logger func wrong output",
+			option: Options(
+				WithLogger(func() *Foo { return &Foo{} }),
+			),
+			error:               ErrUnsupportedLoggerProvider,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "priority",
+			option: Options(
+				Provide(
+					func() *Foo { panic("it must not be called") },
+					func() *Foo { return &Foo{} }, Priority(1),
+					func() *Foo { panic("it must not be called") }, Priority(-1),
+				),
+				Invoke(func(foo *Foo) {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "priority tie",
+			option: Options(
+				Provide(
+					func() *Foo { return &Foo{} }, Priority(1),
+					func() *Foo { return &Foo{} },
+					func() *Foo { return &Foo{} }, Priority(1),
+				),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               ErrMultipleProvide,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "duck typing priority",
+			option: Options(
+				WithDuckTyping(),
+				Supply(&Foo{}),
+				Provide(func() *FooBar { return &FooBar{} }, Priority(1)),
+				Invoke(func(foo IFoo) {
+					if _, ok := foo.(*FooBar); !ok {
+						panic("foo must be *FooBar")
+					}
+				}),
+			),
+		},
+		{
+			name: "error implementations are values",
+			option: Options(
+				Provide(
+					func() (*testError, error) { return &testError{}, nil },
+					func() testTemporaryError { return &testError{} },
+				),
+				Invoke(func(err *testError, tmpErr testTemporaryError) {
+					if err == nil || tmpErr == nil {
+						panic("errors must not be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "supply as",
+			option: Options(
+				Supply(&FooBar{}, As(new(IFoo), new(IBar))),
+				Invoke(func(foo IFoo, bar IBar, fooBar *FooBar) {
+					if foo == nil || bar == nil || fooBar == nil {
+						panic("values must not be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "provide as",
+			option: Options(
+				Provide(func() (*FooBar, error) { return &FooBar{}, nil }, As(new(IFoo))),
+				Invoke(func(foo IFoo) {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "supply as not implemented",
+			option: Options(
+				Supply(&Foo{}, As(new(IBar))),
+			),
+			error:               ErrUnsupportedAnnotation,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "supply as not interface",
+			option: Options(
+				Supply(&Foo{}, As(new(Foo))),
+			),
+			error:               ErrUnsupportedAnnotation,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide config",
+			option: Options(
+				Supply(map[string]any{
+					"db": map[string]any{"host": "localhost", "port": 5432},
+				}),
+				ProvideConfig[testDBConfig]("db"),
+				Invoke(func(cfg testDBConfig) {
+					if cfg.Host != "localhost" || cfg.Port != 5432 {
+						panic("config must be decoded")
+					}
+				}),
+			),
+		},
+		{
+			name: "provide config missing key",
+			option: Options(
+				Supply(map[string]any{}),
+				ProvideConfig[testDBConfig]("db"),
+				Invoke(func(cfg testDBConfig) {}),
+			),
+			error:               ErrConfigDecode,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide config decode error",
+			option: Options(
+				Supply(map[string]any{"db": map[string]any{"port": "not a number"}}),
+				ProvideConfig[testDBConfig]("db"),
+				Invoke(func(cfg testDBConfig) {}),
+			),
+			error:               ErrConfigDecode,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "type timeout",
+			option: Options(
+				WithTypeTimeout(map[reflect.Type]time.Duration{
+					reflect.TypeOf(&Foo{}): 10 * time.Millisecond,
+				}),
+				Provide(func() *Foo {
+					time.Sleep(100 * time.Millisecond)
+					return &Foo{}
+				}),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               context.DeadlineExceeded,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "type timeout not exceeded",
+			option: Options(
+				WithTypeTimeout(map[reflect.Type]time.Duration{
+					reflect.TypeOf(&Bar{}): 10 * time.Millisecond,
+				}),
+				Provide(func() *Foo {
+					time.Sleep(20 * time.Millisecond)
+					return &Foo{}
+				}),
+				Invoke(func(foo *Foo) {}),
+			),
+		},
+		{
+			name: "variadic invoke",
+			option: Options(
+				Supply(&Foo{}, &Foo{}),
+				Provide(func() (*Foo, error) { return &Foo{}, nil }),
+				Invoke(func(bar *Bar, foos ...*Foo) {
+					if bar == nil || len(foos) != 3 {
+						panic("all foos must be collected")
+					}
+				}),
+				Supply(&Bar{}),
+			),
+		},
+		{
+			name: "variadic invoke without values",
+			option: Options(
+				Invoke(func(foos ...*Foo) {
+					if len(foos) != 0 {
+						panic("foos must be empty")
+					}
+				}),
+			),
+		},
+		{
+			name: "duck typing variadic invoke",
+			option: Options(
+				WithDuckTyping(),
+				Supply(&Foo{}, &FooBar{}, &Bar{}),
+				Invoke(func(foos ...IFoo) {
+					if len(foos) != 2 {
+						panic("all foos must be collected")
+					}
+				}),
+			),
+		},
+		{
+			name: "supply lazy",
+			option: Options(
+				SupplyLazy(func() *Foo { return &Foo{} }),
+				SupplyLazy(func() *Bar { panic("it must not be called") }),
+				Invoke(func(foo *Foo) {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+				}, func(foo *Foo) {}),
+			),
+		},
+		{
+			name: "self dependency",
+			option: Options(
+				Provide(func(foo *Foo) *Foo { return foo }),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               ErrSelfDependency,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "self dependency with provider",
+			option: Options(
+				Provide(func(foo *Foo) *Foo { return foo }, func() *Foo { return &Foo{} }),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               ErrMultipleProvide,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "supply as static type",
+			option: Options(
+				SupplyAs[IFoo](&FooBar{}),
+				SupplyAs[IBar](nil),
+				Invoke(func(foo IFoo, bar IBar) {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+					if bar != nil {
+						panic("bar must be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "supply as static type is not concrete",
+			option: Options(
+				SupplyAs[IFoo](&FooBar{}),
+				Invoke(func(fooBar *FooBar) {}),
+			),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide reflect",
+			option: Options(
+				ProvideReflect(reflect.TypeOf(&Foo{}), func() (any, error) { return &Foo{}, nil }),
+				ProvideReflect(reflect.TypeOf((*IBar)(nil)).Elem(), func() (any, error) { return &FooBar{}, nil }),
+				Invoke(func(foo *Foo, bar IBar) {
+					if foo == nil || bar == nil {
+						panic("values must not be nil")
+					}
+				}),
+			),
+		},
+		{
+			name: "provide reflect type mismatch",
+			option: Options(
+				ProvideReflect(reflect.TypeOf(&Foo{}), func() (any, error) { return &Bar{}, nil }),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               ErrTypeMismatch,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide reflect error",
+			option: Options(
+				ProvideReflect(reflect.TypeOf(&Foo{}), func() (any, error) { return nil, provideTestError }),
+				Invoke(func(foo *Foo) {}),
+			),
+			error:               provideTestError,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "duck typing strict supply",
+			option: Options(
+				WithDuckTyping(),
+				WithStrictSupply(),
+				Supply(&FooBar{}, As(new(IBar))),
+				Provide(func(bar IBar) *Foo { return &Foo{} }),
+				Invoke(func(foo IFoo, fooBar *FooBar) {
+					if _, ok := foo.(*Foo); !ok {
+						panic("foo must be provided by constructor")
+					}
+				}),
+			),
+		},
+		{
+			name: "duck typing strict supply missing",
+			option: Options(
+				WithDuckTyping(),
+				WithStrictSupply(),
+				Supply(&FooBar{}),
+				Invoke(func(foo IFoo) {}),
+			),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide as not implemented",
+			option: Options(
+				Provide(func() *Bar { panic("it must not be called") }),
+				Invoke(func(*Bar) {}),
+				Provide(func(*Bar) (*Foo, error) { panic("it must not be called") }, As(new(IBar))),
+			),
+			error:               ErrUnsupportedAnnotation,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "invoke feeds",
+			option: Options(
+				Provide(func(bar *Bar) *Foo { return &Foo{} }),
+				Invoke(func(foo *Foo) {
+					if foo == nil {
+						panic("foo must not be nil")
+					}
+				}),
+				Invoke(func() (*Bar, error) { return &Bar{}, nil }, Feeds()),
+			),
+		},
+		{
+			name: "invoke without feeds",
+			option: Options(
+				Invoke(func(*Bar) {}),
+				Invoke(func() *Bar { return &Bar{} }),
+			),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide feeds",
+			option: Options(
+				Provide(func() *Bar { return &Bar{} }, Feeds()),
+			),
+			error:               ErrUnsupportedAnnotation,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "variadic invoke ordered by priority",
+			option: Options(
+				Provide(func() testEnv { return "low" }, Priority(-1)),
+				Provide(func() testEnv { return "first" }),
+				Provide(func() testEnv { return "high" }, Priority(2)),
+				Provide(func() testEnv { return "second" }),
+				Invoke(func(envs ...testEnv) {
+					if !reflect.DeepEqual(envs, []testEnv{"high", "first", "second", "low"}) {
+						panic(fmt.Sprintf("unexpected order: %v", envs))
+					}
+				}),
+			),
+		},
+		{
+			name: "supply error",
+			option: Options(
+				Supply(&testError{}),
+				Invoke(func(err *testError) {}),
+			),
+			error: ErrUnsupportedSupplyTarget,
+		},
+		{
+			name: "variadic invoke skipping unmet members",
+			option: Options(
+				Supply(testEnv("supplied")),
+				Provide(func(*Foo) testEnv { return "unmet" }),
+				Provide(func(*Bar) testEnv { return "provided" }),
+				Provide(func() *Bar { return &Bar{} }),
+				Invoke(func(envs ...testEnv) {
+					if !reflect.DeepEqual(envs, []testEnv{"supplied", "provided"}) {
+						panic(fmt.Sprintf("unexpected values: %v", envs))
+					}
+				}, SkipUnmetMembers()),
+			),
+		},
+		{
+			name: "variadic invoke with unmet members",
+			option: Options(
+				Provide(func(*Foo) testEnv { return "unmet" }),
+				Invoke(func(envs ...testEnv) {}),
+			),
+			error:               ErrCannotProvideValue,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "warm",
+			option: Options(
+				Provide(func() *Foo { return &Foo{} }),
+				Provide(func() (*Bar, error) { return nil, ErrInternalError }),
+				Warm(new(*Foo), reflect.TypeOf(&Bar{})),
+			),
+			error:               ErrInternalError,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "warm unsupported",
+			option: Options(
+				Provide(func() *Foo { return &Foo{} }),
+				Warm(Foo{}),
+			),
+			error: ErrUnsupportedWarmTarget,
+		},
+		{
+			name: "variadic provide",
+			option: Options(
+				Supply(testEnv("first")),
+				Provide(
+					func() testEnv { return "second" },
+					func(envs ...testEnv) []testEnv { return envs },
+				),
+				Invoke(func(envs []testEnv) {
+					if !reflect.DeepEqual(envs, []testEnv{"first", "second"}) {
+						panic(fmt.Sprintf("unexpected values: %v", envs))
+					}
+				}),
+			),
+		},
+		{
+			name: "invoke self dependency",
+			option: Options(
+				Invoke(func(foo *Foo) *Foo { return foo }),
+			),
+			error:               ErrSelfDependency,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "options from factory",
+			option: OptionsFrom(func() ([]Option, error) {
+				return []Option{Supply(&Foo{}), Invoke(func(*Foo) {})}, nil
+			}),
+		},
+		{
+			name: "options from failed factory",
+			option: OptionsFrom(func() ([]Option, error) {
+				return nil, ErrConfigDecode
+			}),
+			error:               ErrConfigDecode,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "no unused supplies",
+			option: Options(
+				WithNoUnusedSupplies(),
+				Supply(&Foo{}),
+				Provide(func() *Bar { return &Bar{} }),
+				Invoke(func(*Foo) {}),
+			),
+		},
+		{
+			name: "no unused supplies with select case",
+			option: Options(
+				WithNoUnusedSupplies(),
+				Supply(testEnv("prod"), &Bar{}),
+				Select(new(testEnv), map[any]any{
+					testEnv("prod"): func(*Bar) *Foo { return &Foo{} },
+				}),
+				Invoke(func(*Foo) {}),
+			),
+		},
+		{
+			name: "no unused supplies with caller",
+			option: Options(
+				WithNoUnusedSupplies(),
+				Supply(&Bar{}),
+				Provide(func(Caller, *Bar) *Foo { return &Foo{} }),
+				Invoke(func(*Foo) {}),
+			),
+		},
+		{
+			name: "unused supply",
+			option: Options(
+				WithNoUnusedSupplies(),
+				Supply(&Foo{}, &Bar{}),
+				Invoke(func(*Foo) {}),
+			),
+			error:               ErrUnusedSupply,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "convert chain",
+			option: Options(
+				Supply(testEnv("prod")),
+				Convert(func(env testEnv) testDBConfig { return testDBConfig{Host: string(env)} }),
+				Convert(func(cfg testDBConfig) (*testDBConfig, error) { return &cfg, nil }),
+				Invoke(func(cfg *testDBConfig) {
+					if cfg.Host != "prod" {
+						panic("value must be converted")
+					}
+				}),
+			),
+		},
+		{
+			name: "convert after direct provide",
+			option: Options(
+				Supply(testEnv("prod"), testDBConfig{Host: "supplied"}),
+				Convert(func(env testEnv) testDBConfig { panic("it must not be called") }),
+				Invoke(func(cfg testDBConfig) {
+					if cfg.Host != "supplied" {
+						panic("supplied value must be used")
+					}
+				}),
+			),
+		},
+		{
+			name: "convert unsupported",
+			option: Options(
+				Convert(func(testEnv, *Foo) testDBConfig { return testDBConfig{} }),
+			),
+			error:               ErrUnsupportedConvertTarget,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide pool",
+			option: Options(
+				Supply(&Bar{}),
+				ProvidePool(func() int { return 3 }, func(bar *Bar) (*Foo, error) { return &Foo{}, nil }),
+				Invoke(func(foos []*Foo) {
+					if len(foos) != 3 || foos[0] == nil {
+						panic("all foos must be constructed")
+					}
+				}),
+			),
+		},
+		{
+			name: "provide pool error",
+			option: Options(
+				ProvidePool(func() int { return 2 }, func() (*Foo, error) { return nil, ErrInternalError }),
+				Invoke(func(foos []*Foo) {}),
+			),
+			error:               ErrInternalError,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide pool negative size",
+			option: Options(
+				ProvidePool(func() int { return -1 }, func() *Foo { return &Foo{} }),
+				Invoke(func(foos []*Foo) {}),
+			),
+			error:               ErrInvalidPoolSize,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide pool unsupported",
+			option: Options(
+				ProvidePool(func() int { return 2 }, func() (*Foo, *Bar) { return nil, nil }),
+			),
+			error:               ErrUnsupportedProvideTarget,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "provide pool nil size",
+			option: Options(
+				ProvidePool(nil, func() *Foo { return &Foo{} }),
+				Invoke(func(foos []*Foo) {}),
+			),
+			error:               ErrUnsupportedProvideTarget,
+			invokeMustBeSkipped: true,
+		},
+		{
+			name: "duck typing registry lookalike",
+			option: Options(
+				WithDuckTyping(),
+				Provide(func() *testRegistry { return &testRegistry{} }),
+				Invoke(func(*testRegistry) {}),
+			),
+		},
+		{
+			name: "duck typing error sink lookalike",
+			option: Options(
+				WithDuckTyping(),
+				Provide(func() *testReporter { return &testReporter{} }),
+				Invoke(func(*testReporter) {}),
+			),
+		},
+		{
+			name: "select resolves only the chosen case",
+			option: Options(
+				Supply(testEnv("dev")),
+				Select(new(testEnv), map[any]any{
+					testEnv("dev"):  func() IFoo { return &Foo{} },
+					testEnv("prod"): func(*Bar) IFoo { panic("it must not be called") },
+				}),
+				Invoke(func(foo IFoo) {}),
+			),
+		},
+		{
+			name: "duck typing as",
+			option: Options(
+				WithDuckTyping(),
+				Provide(func() *FooBar { return &FooBar{} }, As(new(IFoo))),
+				Invoke(func(foo IFoo, foobar *FooBar) {
+					if foo != foobar {
+						panic("the same value must be injected")
+					}
+				}),
+			),
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			called := false
+
+			err := Revolve(ctx, testCase.option, Invoke(func() {
+				called = true
+			}))
+			if err != testCase.error {
+				if !(err == nil || testCase.error == nil) {
+					if errors.Is(err, testCase.error) {
+						return
+					}
+				}
+				t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, testCase.error)
+			}
+			if called == testCase.invokeMustBeSkipped {
+				t.Fatal("invoke func must be called")
+			}
+		})
+	}
+}
+
+type Foo struct{}
+
+func (Foo) foo() {}
+
+type Bar struct{}
+
+func (Bar) bar() {}
+
+type Buzz struct{}
+
+func (Buzz) buzz() {}
+
+type IFoo interface {
+	foo()
+}
+
+type IBar interface {
+	bar()
+}
+
+type FooBar struct{}
+
+func (FooBar) foo() {}
+func (FooBar) bar() {}
+
+type testEnv string
+
+type testDBConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type testError struct{}
+
+func (*testError) Error() string   { return "test error" }
+func (*testError) Temporary() bool { return true }
+
+type testTemporaryError interface {
+	error
+	Temporary() bool
+}
+
+func customLogFunc(lvl LogLevel, format string, args ...any) {
+	switch lvl {
+	case LogLevelWarn:
+		log.Printf("customLogFunc: warn:"+format, args...)
+	case LogLevelInfo:
+		log.Printf("customLogFunc: "+format, args...)
+	case LogLevelDebug:
+		log.Printf("customLogFunc: debug:"+format, args...)
+	}
+}
+
+type namedLogFunc func(lvl LogLevel, format string, args ...any)
+
+type customLogger struct{}
+
+func (l customLogger) Printf(lvl LogLevel, format string, args ...any) {
+	switch lvl {
+	case LogLevelWarn:
+		log.Printf("customLogger: warn:"+format, args...)
+	case LogLevelInfo:
+		log.Printf("customLogger: "+format, args...)
+	case LogLevelDebug:
+		log.Printf("customLogger: debug:"+format, args...)
+	}
+}
+
+var provideTestError = errors.New("provide test err")
+var invokeTestError = errors.New("invoke test err")
+
+func TestLinkError(t *testing.T) {
+	err := Revolve(context.Background(),
+		Provide(func() *Foo { return &Foo{} }, func() *Foo { return &Foo{} }),
+		Invoke(func(foo *Foo, bar *Bar) {}),
+	)
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("error must be a LinkError: %v", err)
+	}
+	if !errors.Is(err, ErrMultipleProvide) {
+		t.Fatalf("error must wrap ErrMultipleProvide: %v", err)
+	}
+	if linkErr.Type != reflect.TypeOf(&Foo{}) {
+		t.Fatalf("unexpected type: %s", linkErr.Type)
+	}
+	if len(linkErr.Providers) != 2 {
+		t.Fatalf("unexpected providers: %v", linkErr.Providers)
+	}
+
+	err = Revolve(context.Background(),
+		WithDuckTyping(),
+		Provide(func() (*Bar, error) { return &Bar{}, nil }, func() (*Buzz, *FooBar) { return &Buzz{}, &FooBar{} }),
+		Invoke(func(bar IBar) {}),
+	)
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrMultipleProvide) {
+		t.Fatalf("error must be a LinkError wrapping ErrMultipleProvide: %v", err)
+	}
+	if !strings.HasPrefix(linkErr.Providers[0], "output #0 *rv.Bar of ") ||
+		!strings.HasPrefix(linkErr.Providers[1], "output #1 *rv.FooBar of ") {
+		t.Fatalf("conflicting outputs must be described: %v", linkErr.Providers)
+	}
+
+	err = Revolve(context.Background(), Invoke(func(bar *Bar) {}))
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrCannotProvideValue) {
+		t.Fatalf("error must be a LinkError wrapping ErrCannotProvideValue: %v", err)
+	}
+	if linkErr.Type != reflect.TypeOf(&Bar{}) {
+		t.Fatalf("unexpected type: %s", linkErr.Type)
+	}
+}
+
+func TestNoDeadlineWarning(t *testing.T) {
+	warnings := 0
+	logger := LogFunc(func(lvl LogLevel, format string, args ...any) {
+		if lvl == LogLevelWarn {
+			warnings++
+		}
+	})
+
+	if err := Revolve(context.Background(), WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 1 {
+		t.Fatalf("expected 1 warning, got %d", warnings)
+	}
+
+	warnings = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Revolve(ctx, WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 0 {
+		t.Fatalf("expected no warnings, got %d", warnings)
+	}
+
+	if err := Revolve(context.Background(), WithLogger(logger), WithProviderTimeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 0 {
+		t.Fatalf("expected no warnings with provider timeout, got %d", warnings)
+	}
+}
+
+func TestLogLevelValues(t *testing.T) {
+	levels := []LogLevel{LogLevelSilence, LogLevelInfo, LogLevelDebug, LogLevelWarn}
+	for i, lvl := range levels {
+		if lvl != LogLevel(i) {
+			t.Fatalf("log level values must be stable: %d expected, got %d", i, lvl)
+		}
+	}
+}
+
+func TestProvideFuncWarning(t *testing.T) {
+	type handler func()
+	newHandler := func() handler { return func() {} }
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	logger := &CountingLogger{}
+	if err := Revolve(ctx, WithLogger(logger), Provide(newHandler), Invoke(func(handler) {})); err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.Counts()[LogLevelWarn]; warnings != 1 {
+		t.Fatalf("expected 1 warning, got %d", warnings)
+	}
+
+	logger = &CountingLogger{}
+	err := Revolve(ctx, WithLogger(logger), Provide(newHandler, ProvidesFunc()), Invoke(func(handler) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.Counts()[LogLevelWarn]; warnings != 0 {
+		t.Fatalf("expected no warnings, got %d", warnings)
 	}
+}
 
-	t.Parallel()
-	for _, testCase := range testCases {
-		t.Run(testCase.name, func(t *testing.T) {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-			defer cancel()
+func TestShadowedSupplyWarning(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	logger := &CountingLogger{}
+	err := Revolve(ctx, WithLogger(logger),
+		Supply(&Foo{}, &Bar{}),
+		Provide(func() *Foo { return &Foo{} }, Priority(1)),
+		Invoke(func(*Foo, *Bar) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.Counts()[LogLevelWarn]; warnings != 1 {
+		t.Fatalf("expected 1 warning, got %d", warnings)
+	}
+}
 
-			called := false
+func TestPopulateStruct(t *testing.T) {
+	var deps struct {
+		Foo *Foo
+		Bar Bar
+	}
+	err := Revolve(context.Background(),
+		Provide(func() *Foo { return &Foo{} }),
+		Supply(Bar{}),
+		PopulateStruct(&deps),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps.Foo == nil {
+		t.Fatal("foo must not be nil")
+	}
+}
 
-			err := Revolve(ctx, testCase.option, Invoke(func() {
-				called = true
-			}))
-			if err != testCase.error {
-				if !(err == nil || testCase.error == nil) {
-					if errors.Is(err, testCase.error) {
-						return
-					}
+func TestProgress(t *testing.T) {
+	var reports [][2]int
+	err := Revolve(context.Background(),
+		WithProgress(func(done, total int) {
+			reports = append(reports, [2]int{done, total})
+		}),
+		Supply(testEnv("prod")),
+		Provide(func() *Bar { return &Bar{} }),
+		Provide(func() *Buzz { panic("it must not be called") }),
+		Select(new(testEnv), map[any]any{
+			testEnv("prod"): func(bar *Bar) *Foo { return &Foo{} },
+		}),
+		Invoke(func(foo *Foo) {}),
+		Invoke(func(foo *Foo) *FooBar { return &FooBar{} }, Feeds()),
+		Invoke(func(*FooBar) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// supply is done from the start, select case and synthetic supplies are not counted,
+	// unused provide is skipped and reported as done when the resolution completes
+	exp := [][2]int{{2, 7}, {3, 7}, {4, 7}, {5, 7}, {6, 7}, {7, 7}}
+	if !reflect.DeepEqual(reports, exp) {
+		t.Fatalf("unexpected progress: %v", reports)
+	}
+
+	reports = nil
+	err = Revolve(context.Background(),
+		WithProgress(func(done, total int) {
+			reports = append(reports, [2]int{done, total})
+		}),
+		WithLogger(func() Logger { return LogFunc(devNull) }),
+		Invoke(func() {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the logger constructor is counted, the invoke setting the logger is not
+	if exp := [][2]int{{1, 2}, {2, 2}}; !reflect.DeepEqual(reports, exp) {
+		t.Fatalf("unexpected progress with logger: %v", reports)
+	}
+}
+
+func BenchmarkRevolve(b *testing.B) {
+	const providersCount = 500
+
+	// every provider returns its own struct type and depends on the previous one
+	types := make([]reflect.Type, providersCount)
+	for i := range types {
+		types[i] = reflect.StructOf([]reflect.StructField{{
+			Name: fmt.Sprintf("F%d", i),
+			Type: reflect.TypeOf(0),
+		}})
+	}
+	newOptions := func() Option {
+		opts := make([]Option, 0, providersCount+1)
+		for i, typ := range types {
+			var in []reflect.Type
+			if i > 0 {
+				in = append(in, types[i-1])
+			}
+			typ := typ
+			fn := reflect.MakeFunc(reflect.FuncOf(in, []reflect.Type{typ}, false), func([]reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.Zero(typ)}
+			})
+			opts = append(opts, Provide(fn.Interface()))
+		}
+		fn := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{types[providersCount-1]}, nil, false),
+			func([]reflect.Value) []reflect.Value { return nil })
+		opts = append(opts, Invoke(fn.Interface()))
+		return Options(opts...)
+	}
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := Revolve(context.Background(), newOptions()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("duck typing", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := Revolve(context.Background(), WithDuckTyping(), newOptions()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRequireInvoke(t *testing.T) {
+	err := Revolve(context.Background(), WithRequireInvoke(), Supply(&Foo{}))
+	if !errors.Is(err, ErrNoInvoke) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrNoInvoke)
+	}
+	err = Revolve(context.Background(), WithRequireInvoke(), Supply(&Foo{}), Invoke(func(foo *Foo) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnambiguousInterfaces(t *testing.T) {
+	opts := Options(
+		WithDuckTyping(),
+		Provide(func() *Foo { return &Foo{} }, Priority(1)),
+		Provide(func() *FooBar { return &FooBar{} }),
+		Invoke(func(IFoo) {}),
+	)
+	if err := Revolve(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	err := Revolve(context.Background(), WithUnambiguousInterfaces(), opts)
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrAmbiguousInterface) {
+		t.Fatalf("error must be a LinkError wrapping ErrAmbiguousInterface: %v", err)
+	}
+	if len(linkErr.Providers) != 2 {
+		t.Fatalf("both providers must be reported: %v", linkErr.Providers)
+	}
+	err = Revolve(context.Background(), WithDuckTyping(), WithUnambiguousInterfaces(),
+		Provide(func() *Foo { return &Foo{} }), Invoke(func(IFoo) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// strict supplies match exact types only, so they are not candidates of interfaces
+	err = Revolve(context.Background(), WithDuckTyping(), WithStrictSupply(), WithUnambiguousInterfaces(),
+		Supply(&FooBar{}), Provide(func() *Foo { return &Foo{} }), Invoke(func(IFoo) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnInvoke(t *testing.T) {
+	var finished []string
+	var errs []error
+	err := Revolve(context.Background(),
+		WithOnInvoke(func(name string, err error) {
+			finished = append(finished, name)
+			errs = append(errs, err)
+		}),
+		Supply(&Foo{}),
+		Invoke(invokeTestFoo),
+		Invoke(func(*Foo) error { return ErrInternalError }),
+		Invoke(func(*Foo) {}),
+	)
+	if !errors.Is(err, ErrInternalError) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrInternalError)
+	}
+	if len(finished) != 2 || !strings.HasSuffix(finished[0], ".invokeTestFoo") {
+		t.Fatalf("unexpected finished invokes: %v", finished)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], ErrInternalError) {
+		t.Fatalf("unexpected invoke errors: %v", errs)
+	}
+}
+
+func TestAutoClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	closed := make(chan string, 3)
+
+	err := Revolve(ctx,
+		WithAutoClose(),
+		Provide(func() *testCloser { return &testCloser{name: "first", closed: closed} }),
+		Provide(func(*testCloser) *Foo { return &Foo{} }),
+		Provide(func(*Foo) testCloser { return testCloser{name: "second", closed: closed} }),
+		Provide(func(testCloser) *Bar { return &Bar{} }, NoAutoClose()),
+		Provide(func(*Bar) io.Closer { return &testCloser{name: "skipped", closed: closed} }, NoAutoClose()),
+		Invoke(func(io.Closer) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-closed:
+		t.Fatalf("%s must not be closed before the context is done", name)
+	default:
+	}
+
+	cancel()
+	for _, exp := range []string{"second", "first"} {
+		select {
+		case name := <-closed:
+			if name != exp {
+				t.Fatalf("unexpected close order: got %s exp %s", name, exp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s must be closed", exp)
+		}
+	}
+}
+
+func TestAutoCloseAs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	closed := make(chan string, 2)
+
+	err := Revolve(ctx,
+		WithAutoClose(),
+		Provide(func() *testCloser { return &testCloser{name: "bound", closed: closed} }, As(new(io.Closer))),
+		Invoke(func(io.Closer) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("bound must be closed")
+	}
+	select {
+	case <-closed:
+		t.Fatal("bound must be closed once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cleaned := make(chan string, 3)
+
+	err := Revolve(ctx,
+		Provide(func() (*Foo, func(), error) {
+			return &Foo{}, func() { cleaned <- "foo" }, nil
+		}, Cleanup()),
+		Provide(func(*Foo) (func(), error) {
+			return func() { cleaned <- "setup" }, nil
+		}, Cleanup()),
+		Invoke(func(*Foo) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-cleaned:
+		t.Fatalf("%s must not be cleaned before the context is done", name)
+	default:
+	}
+
+	cancel()
+	for _, exp := range []string{"setup", "foo"} {
+		select {
+		case name := <-cleaned:
+			if name != exp {
+				t.Fatalf("unexpected cleanup order: got %s exp %s", name, exp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s must be cleaned", exp)
+		}
+	}
+
+	err = Revolve(context.Background(), Provide(func() *Foo { return &Foo{} }, Cleanup()))
+	if !errors.Is(err, ErrUnsupportedAnnotation) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrUnsupportedAnnotation)
+	}
+	err = Revolve(context.Background(),
+		Provide(func() (*Foo, func()) { return &Foo{}, func() {} }, Cleanup()),
+		Invoke(func(func()) {}),
+	)
+	if !errors.Is(err, ErrCannotProvideValue) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrCannotProvideValue)
+	}
+}
+
+func TestCaller(t *testing.T) {
+	type taggedLogger struct{ tag Caller }
+	var provided, invoked []Caller
+	err := Revolve(context.Background(),
+		Provide(func(caller Caller) *taggedLogger {
+			provided = append(provided, caller)
+			return &taggedLogger{tag: caller}
+		}),
+		Invoke(func(caller Caller, logger *taggedLogger) {
+			if logger.tag != caller {
+				panic("logger must be tagged with the consumer")
+			}
+			invoked = append(invoked, caller)
+		}),
+		Invoke(func(caller Caller, logger *taggedLogger) {
+			if logger.tag != caller {
+				panic("logger must be tagged with the consumer")
+			}
+			invoked = append(invoked, caller)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoked) != 2 || invoked[0] == invoked[1] ||
+		!strings.HasPrefix(string(invoked[0]), "github.com/axelzv9/rv.TestCaller.func") {
+		t.Fatalf("invokes must receive their own names: %v", invoked)
+	}
+	if !reflect.DeepEqual(provided, invoked) {
+		t.Fatalf("provider must be called once per consumer with its name: %v", provided)
+	}
+
+	var reports [][2]int
+	err = Revolve(context.Background(),
+		WithProgress(func(done, total int) {
+			reports = append(reports, [2]int{done, total})
+		}),
+		Provide(func(caller Caller) *taggedLogger { return &taggedLogger{tag: caller} }),
+		Invoke(func(*taggedLogger) {}),
+		Invoke(func(*taggedLogger) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the provider is replaced by its clones, one per consumer
+	if exp := [][2]int{{1, 3}, {2, 4}, {3, 4}, {4, 4}}; !reflect.DeepEqual(reports, exp) {
+		t.Fatalf("unexpected progress: %v", reports)
+	}
+}
+
+func TestAccessor(t *testing.T) {
+	calls := 0
+	err := Revolve(context.Background(),
+		Provide(func() *Foo {
+			calls++
+			return &Foo{}
+		}),
+		Provide(func() (*Bar, error) { return nil, ErrInternalError }),
+		Invoke(func(getFoo func() *Foo, getBar func() (*Bar, error)) {
+			if calls != 0 {
+				panic("foo must be constructed on demand")
+			}
+			if getFoo() == nil || getFoo() != getFoo() || calls != 1 {
+				panic("foo must be constructed once")
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := getBar(); !errors.Is(err, ErrInternalError) {
+					panic(fmt.Sprintf("unexpected error: %v", err))
 				}
-				t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, testCase.error)
 			}
-			if called == testCase.invokeMustBeSkipped {
-				t.Fatal("invoke func must be called")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Revolve(context.Background(), Invoke(func(func() (*Foo, error)) {}))
+	if !errors.Is(err, ErrCannotProvideValue) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrCannotProvideValue)
+	}
+}
+
+type testCloser struct {
+	name   string
+	closed chan<- string
+}
+
+func (c testCloser) Close() error {
+	c.closed <- c.name
+	return nil
+}
+
+func TestCallMiddleware(t *testing.T) {
+	var calls []CallInfo
+	err := Revolve(context.Background(),
+		WithCallMiddleware(func(ctx context.Context, next func(context.Context) error, info CallInfo) error {
+			calls = append(calls, info)
+			return next(ctx)
+		}),
+		Supply(&Bar{}),
+		Provide(func(*Bar) (*Foo, error) { return &Foo{}, nil }),
+		Invoke(func(*Foo) error { return invokeTestError }),
+	)
+	if !errors.Is(err, invokeTestError) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, invokeTestError)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if len(calls[0].Inputs) != 1 || calls[0].Inputs[0] != reflect.TypeOf(&Bar{}) || len(calls[0].Outputs) != 2 {
+		t.Fatalf("unexpected provide call info: %+v", calls[0])
+	}
+
+	err = Revolve(context.Background(),
+		WithCallMiddleware(func(ctx context.Context, next func(context.Context) error, info CallInfo) error {
+			return nil
+		}),
+		Invoke(func() {}),
+	)
+	if !errors.Is(err, ErrInternalError) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrInternalError)
+	}
+
+	type spanKey struct{}
+	var spans []any
+	err = Revolve(context.Background(),
+		WithCallMiddleware(func(ctx context.Context, next func(context.Context) error, info CallInfo) error {
+			return next(context.WithValue(ctx, spanKey{}, info.Name))
+		}),
+		WithContextWrapper(func(ctx context.Context, info CallInfo) context.Context {
+			spans = append(spans, ctx.Value(spanKey{}))
+			return ctx
+		}),
+		Invoke(func() {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 || spans[0] == nil {
+		t.Fatalf("call context must be derived from the middleware context: %v", spans)
+	}
+}
+
+func TestCannotProvideValueHints(t *testing.T) {
+	type port int
+	err := Revolve(context.Background(), Supply(port(8080)), Invoke(func(int) {}))
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrCannotProvideValue) {
+		t.Fatalf("error must be a LinkError wrapping ErrCannotProvideValue: %v", err)
+	}
+	if len(linkErr.Hints) != 1 {
+		t.Fatalf("expected 1 hint, got: %v", linkErr.Hints)
+	}
+
+	for _, opt := range []Option{
+		Options(Supply(int64(8080)), Invoke(func(int) {})),
+		Options(Supply(8080, []byte("8080")), Invoke(func(string) {})),
+		Options(Supply([]int{8080}), Invoke(func([]port) {})),
+	} {
+		err = Revolve(context.Background(), opt)
+		if !errors.As(err, &linkErr) || !errors.Is(err, ErrCannotProvideValue) {
+			t.Fatalf("error must be a LinkError wrapping ErrCannotProvideValue: %v", err)
+		}
+		if len(linkErr.Hints) != 0 {
+			t.Fatalf("expected no hints, got: %v", linkErr.Hints)
+		}
+	}
+}
+
+func TestUnreachedInvokesWarning(t *testing.T) {
+	var warnings []string
+	err := Revolve(context.Background(),
+		WithLogger(func(lvl LogLevel, format string, args ...any) {
+			if lvl == LogLevelWarn {
+				warnings = append(warnings, fmt.Sprintf(format, args...))
 			}
-		})
+		}),
+		Provide(func() (*Foo, error) { return nil, provideTestError }),
+		Invoke(func() {}, func(*Foo) {}, func() {}),
+	)
+	if !errors.Is(err, provideTestError) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, provideTestError)
+	}
+	if len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1], "have not been reached") {
+		t.Fatalf("unreached invokes must be logged: %v", warnings)
+	}
+	if n := strings.Count(warnings[len(warnings)-1], "\n"); n != 3 {
+		t.Fatalf("all 3 invokes must be unreached, got %d: %v", n, warnings)
 	}
 }
 
-type Foo struct{}
+func TestCountingLogger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
 
-func (Foo) foo() {}
+	logger := &CountingLogger{}
+	err := Revolve(ctx,
+		WithLogger(logger),
+		Provide(func() *Foo { return &Foo{} }),
+		Invoke(func(foo *Foo) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := logger.Counts()
+	if counts[LogLevelWarn] != 0 {
+		t.Fatalf("unexpected warnings: %d", counts[LogLevelWarn])
+	}
+	if counts[LogLevelInfo] == 0 || counts[LogLevelDebug] == 0 {
+		t.Fatalf("info and debug messages must be counted: %v", counts)
+	}
+}
 
-type Bar struct{}
+func TestLogPrefix(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
 
-func (Bar) bar() {}
+	var messages []string
+	err := Revolve(ctx,
+		WithLogPrefix("[rv%] "),
+		WithLogger(LogFunc(func(lvl LogLevel, format string, args ...any) {
+			messages = append(messages, fmt.Sprintf(format, args...))
+		})),
+		Supply(&Foo{}),
+		Invoke(func(foo *Foo) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) == 0 {
+		t.Fatal("messages must be logged")
+	}
+	for _, message := range messages {
+		if !strings.HasPrefix(message, "[rv%] ") {
+			t.Fatalf("message must be prefixed: %s", message)
+		}
+	}
+}
 
-type Buzz struct{}
+func TestSummary(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
 
-func (Buzz) buzz() {}
+	var messages []string
+	err := Revolve(ctx,
+		WithLogger(LogFunc(func(lvl LogLevel, format string, args ...any) {
+			messages = append(messages, fmt.Sprintf(format, args...))
+		})),
+		Supply(&Foo{}),
+		Provide(func(*Foo) *Bar { return &Bar{} }),
+		Invoke(func(*Bar) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := messages[len(messages)-1]
+	if !strings.HasPrefix(summary, "resolved 1 provides, 1 supplies, 1 invokes in ") {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+}
 
-type IFoo interface {
-	foo()
+func TestErrorSink(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := Revolve(ctx, Invoke(func(sink ErrorSink) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sink.Report(invokeTestError)
+			sink.Report(provideTestError)
+		}()
+	}))
+	if !errors.Is(err, invokeTestError) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, invokeTestError)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Revolve(ctx, Invoke(func(sink ErrorSink) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("revolve must block until the context is done")
+	}
+
+	report := func(sink ErrorSink) *Foo {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sink.Report(provideTestError)
+		}()
+		return &Foo{}
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = Revolve(ctx,
+		Supply(testEnv("prod")),
+		Select(new(testEnv), map[any]any{testEnv("prod"): report}),
+		Invoke(func(*Foo) {}),
+	)
+	if !errors.Is(err, provideTestError) {
+		t.Fatalf("error reported by a select case must be returned: %v", err)
+	}
+
+	err = Revolve(ctx,
+		Provide(func(_ Caller, sink ErrorSink) *Foo { return report(sink) }),
+		Invoke(func(*Foo) {}),
+	)
+	if !errors.Is(err, provideTestError) {
+		t.Fatalf("error reported by a caller provider must be returned: %v", err)
+	}
 }
 
-type IBar interface {
-	bar()
+func TestFlameOutput(t *testing.T) {
+	var out bytes.Buffer
+	err := Revolve(context.Background(),
+		WithFlameOutput(&out),
+		Supply(&Bar{}),
+		Provide(newTestFoo),
+		Invoke(invokeTestFoo),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "root;github.com/axelzv9/rv.invokeTestFoo;github.com/axelzv9/rv.newTestFoo ") {
+		t.Fatalf("unexpected provide line: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "root;github.com/axelzv9/rv.invokeTestFoo ") {
+		t.Fatalf("unexpected invoke line: %s", lines[1])
+	}
+
+	out.Reset()
+	err = Revolve(context.Background(),
+		WithDryRun(),
+		WithFlameOutput(&out),
+		Supply(&Bar{}),
+		Provide(newTestFoo),
+		Invoke(invokeTestFoo),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("dry run must not be written: %s", out.String())
+	}
 }
 
-type FooBar struct{}
+func newTestFoo(*Bar) *Foo { return &Foo{} }
 
-func (FooBar) foo() {}
-func (FooBar) bar() {}
+func invokeTestFoo(*Foo) {}
 
-func customLogFunc(lvl LogLevel, format string, args ...any) {
-	switch lvl {
-	case LogLevelInfo:
-		log.Printf("customLogFunc: "+format, args...)
-	case LogLevelDebug:
-		log.Printf("customLogFunc: debug:"+format, args...)
+func TestProviderTimeout(t *testing.T) {
+	err := Revolve(context.Background(),
+		WithProviderTimeout(10*time.Millisecond),
+		Provide(func() *Foo { return &Foo{} }),
+		Invoke(func(*Foo) { time.Sleep(50 * time.Millisecond) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Revolve(context.Background(),
+		WithProviderTimeout(10*time.Millisecond),
+		Provide(func() *Foo {
+			time.Sleep(50 * time.Millisecond)
+			return &Foo{}
+		}),
+		Invoke(func(*Foo) {}),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, context.DeadlineExceeded)
 	}
 }
 
-type customLogger struct{}
+func TestContextWrapper(t *testing.T) {
+	var wrapped []string
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	err := Revolve(context.Background(),
+		WithContextWrapper(func(ctx context.Context, info CallInfo) context.Context {
+			wrapped = append(wrapped, info.Name)
+			return ctx
+		}),
+		WithContextWrapper(func(ctx context.Context, info CallInfo) context.Context {
+			if len(info.Outputs) == 0 {
+				return ctx
+			}
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			cancels = append(cancels, cancel)
+			return ctx
+		}),
+		Provide(func() *Foo {
+			time.Sleep(50 * time.Millisecond)
+			return &Foo{}
+		}),
+		Invoke(func(*Foo) {}),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, context.DeadlineExceeded)
+	}
+	if len(wrapped) != 1 {
+		t.Fatalf("unexpected wrapped calls: %v", wrapped)
+	}
+}
 
-func (l customLogger) Printf(lvl LogLevel, format string, args ...any) {
-	switch lvl {
-	case LogLevelInfo:
-		log.Printf("customLogger: "+format, args...)
-	case LogLevelDebug:
-		log.Printf("customLogger: debug:"+format, args...)
+func TestClock(t *testing.T) {
+	var messages []string
+	err := Revolve(context.Background(),
+		WithClock(&stepClock{step: 5 * time.Millisecond}),
+		WithLogger(func(lvl LogLevel, format string, args ...any) {
+			messages = append(messages, fmt.Sprintf(format, args...))
+		}),
+		Provide(newTestFoo),
+		Supply(&Bar{}),
+		Invoke(invokeTestFoo),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed := 0
+	for _, message := range messages {
+		if strings.HasPrefix(message, "executing ") {
+			completed++
+			if !strings.HasSuffix(message, " completed in 5ms") {
+				t.Fatalf("unexpected duration: %s", message)
+			}
+		}
+	}
+	if completed != 2 {
+		t.Fatalf("expected 2 completed calls, got %d", completed)
 	}
 }
 
-var provideTestError = errors.New("provide test err")
-var invokeTestError = errors.New("invoke test err")
+// stepClock advances by step on every call of Now.
+type stepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func TestProvideLeased(t *testing.T) {
+	clock := &stepClock{now: time.Now(), step: time.Second}
+	leases := 0
+	lease := func() (testEnv, time.Time, error) {
+		leases++
+		return testEnv(fmt.Sprint("token", leases)), clock.now.Add(time.Millisecond), nil
+	}
+
+	var tokens []testEnv
+	err := Revolve(context.Background(),
+		WithClock(clock),
+		ProvideLeased(lease),
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0] == tokens[1] {
+		t.Fatalf("expired token must be leased again: %v", tokens)
+	}
+
+	leases, tokens = 0, nil
+	clock.step = 0
+	err = Revolve(context.Background(),
+		WithClock(clock),
+		ProvideLeased(lease),
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tokens, []testEnv{"token1", "token1"}) {
+		t.Fatalf("token must be reused until expiry: %v", tokens)
+	}
+
+	leases, tokens = 0, nil
+	clock.step = time.Second
+	bundle, err := NewBundle(ProvideLeased(lease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Revolve(context.Background(),
+		WithClock(clock),
+		bundle,
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+		Invoke(func(token testEnv) { tokens = append(tokens, token) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0] == tokens[1] {
+		t.Fatalf("expired bundle token must be leased again: %v", tokens)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	_, err := NewBundle(Provide(&Foo{}))
+	if !errors.Is(err, ErrUnsupportedProvideTarget) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, ErrUnsupportedProvideTarget)
+	}
+
+	calls := 0
+	bundle, err := NewBundle(
+		Supply(&Bar{}),
+		Provide(func(*Bar) *Foo {
+			calls++
+			return &Foo{}
+		}),
+		Invoke(func(*Foo) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err = Revolve(context.Background(), bundle, Invoke(func(*Foo) {})); err != nil {
+			t.Fatal(err)
+		}
+		if calls != i {
+			t.Fatalf("provide must be called once per run, got %d calls after %d runs", calls, i)
+		}
+	}
+
+	dryRun, err := NewBundle(WithDryRun(), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Revolve(context.Background(), dryRun); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("dry run bundle must not call provide, got %d calls", calls)
+	}
+}
+
+func TestFeedsConflict(t *testing.T) {
+	err := Revolve(context.Background(),
+		Invoke(func() *Bar { return &Bar{} }, Feeds()),
+		Invoke(func() *Bar { return &Bar{} }, Feeds()),
+		Invoke(func(*Bar) {}),
+	)
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrMultipleProvide) {
+		t.Fatalf("error must be a LinkError wrapping ErrMultipleProvide: %v", err)
+	}
+	for _, provider := range linkErr.Providers {
+		if !strings.Contains(provider, "of feeding invoke ") {
+			t.Fatalf("provider must be described as a feeding invoke: %s", provider)
+		}
+	}
+}
+
+func TestAsConflict(t *testing.T) {
+	err := Revolve(context.Background(),
+		Provide(func() *Foo { return &Foo{} }, As(new(IFoo))),
+		Provide(func() *FooBar { return &FooBar{} }, As(new(IFoo))),
+		Invoke(func(IFoo) {}),
+	)
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrMultipleProvide) {
+		t.Fatalf("error must be a LinkError wrapping ErrMultipleProvide: %v", err)
+	}
+	for i, underlying := range []string{"*rv.Foo", "*rv.FooBar"} {
+		exp := "As(rv.IFoo) binding of output #0 " + underlying
+		if !strings.HasPrefix(linkErr.Providers[i], exp) {
+			t.Fatalf("As binding must be described: \ngot: %s \nexp: %s", linkErr.Providers[i], exp)
+		}
+	}
+}
+
+func TestGroupAggregate(t *testing.T) {
+	type route string
+	type router struct{ routes []route }
+
+	var order []string
+	err := Revolve(context.Background(),
+		Provide(func(*Foo) route {
+			order = append(order, "users")
+			return "/users"
+		}),
+		Provide(func(*router) *Bar {
+			order = append(order, "bar")
+			return &Bar{}
+		}),
+		Provide(func(routes ...route) *router {
+			order = append(order, "router")
+			return &router{routes: routes}
+		}),
+		Provide(func() route {
+			order = append(order, "orders")
+			return "/orders"
+		}, Priority(1)),
+		Supply(&Foo{}),
+		Invoke(func(r *router, _ *Bar) {
+			if !reflect.DeepEqual(r.routes, []route{"/orders", "/users"}) {
+				panic(fmt.Sprintf("unexpected routes: %v", r.routes))
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"orders", "users", "router", "bar"}) {
+		t.Fatalf("group members must be constructed before the aggregate: %v", order)
+	}
+}
+
+func TestBundleFeeds(t *testing.T) {
+	calls := 0
+	bundle, err := NewBundle(Invoke(func() *Bar {
+		calls++
+		return &Bar{}
+	}, Feeds()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Revolve(context.Background(), bundle, Invoke(func(*Bar) {})); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("feeding invoke must be called once, got %d", calls)
+	}
+}
+
+func TestCallGuard(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	logger := &CountingLogger{}
+	err := Revolve(ctx,
+		WithLogger(logger),
+		WithCallGuard(10*time.Millisecond),
+		Provide(func() *Foo {
+			time.Sleep(50 * time.Millisecond)
+			return &Foo{}
+		}),
+		Invoke(func(foo *Foo) {
+			if foo == nil {
+				panic("foo must not be nil")
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.Counts()[LogLevelWarn]; warnings != 1 {
+		t.Fatalf("expected 1 warning, got %d", warnings)
+	}
+}
+
+func TestCannotProvideValueSimilarPackageHints(t *testing.T) {
+	err := Revolve(context.Background(), Provide(test.NewBar), Invoke(func(*test2.Bar) {}))
+	var linkErr *LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(err, ErrCannotProvideValue) {
+		t.Fatalf("error must be a LinkError wrapping ErrCannotProvideValue: %v", err)
+	}
+	if len(linkErr.Hints) != 1 || !strings.Contains(linkErr.Hints[0], `"github.com/axelzv9/rv/testdata/test"`) {
+		t.Fatalf("package of similar type must be hinted: %v", linkErr.Hints)
+	}
+}
+
+func TestGenericInstantiations(t *testing.T) {
+	type user struct{}
+	type order struct{}
+	var users *test.Repository[user]
+	var orders *test.Repository[order]
+	err := Revolve(context.Background(),
+		Provide(test.NewRepository[user]),
+		Provide(test.NewRepository[order]),
+		Invoke(func(u *test.Repository[user], o *test.Repository[order]) {
+			users, orders = u, o
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users == nil || orders == nil {
+		t.Fatal("both instantiations must be provided")
+	}
+
+	fn, err := parseProvide(test.NewRepository[user])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "*github.com/axelzv9/rv/testdata/test.Repository[github.com/axelzv9/rv.user"
+	if !strings.Contains(fn.String(), want) || !strings.Contains(fn.Debug(), want) {
+		t.Fatalf("generic type must be qualified: %s", fn.String())
+	}
+}
+
+type testRegistry struct{}
+
+func (*testRegistry) Types() []reflect.Type { return nil }
+func (*testRegistry) Has(reflect.Type) bool { return false }
+
+type testReporter struct{}
+
+func (*testReporter) Report(error) {}
@@ -0,0 +1,20 @@
+module github.com/axelzv9/rv/rvotel
+
+go 1.19
+
+// rv is not tagged yet, require the tag once it is released.
+replace github.com/axelzv9/rv => ../
+
+require (
+	github.com/axelzv9/rv v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+)
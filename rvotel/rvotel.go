@@ -0,0 +1,34 @@
+// Package rvotel traces rv resolution with OpenTelemetry.
+//
+// The package is a separate module keeping the OpenTelemetry dependency out of rv,
+// its tests are run from the rvotel directory.
+package rvotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/axelzv9/rv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware records a span named after the function for every provide and invoke call.
+// Spans are children of the span from the resolution context, if any, and the call context
+// carries the span of the call.
+func Middleware(tracer trace.Tracer) rv.CallMiddleware {
+	return func(ctx context.Context, next func(context.Context) error, info rv.CallInfo) error {
+		ctx, span := tracer.Start(ctx, info.Name)
+		defer span.End()
+
+		start := time.Now()
+		err := next(ctx)
+		span.SetAttributes(attribute.Int64("rv.duration_ms", time.Since(start).Milliseconds()))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,56 @@
+package rvotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/axelzv9/rv"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type foo struct{}
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("rvotel")
+	errFailed := errors.New("failed")
+
+	ctx, parent := tracer.Start(context.Background(), "startup")
+	defer parent.End()
+	var callSpans []trace.SpanContext
+	err := rv.Revolve(ctx,
+		rv.WithCallMiddleware(Middleware(tracer)),
+		rv.WithContextWrapper(func(ctx context.Context, info rv.CallInfo) context.Context {
+			callSpans = append(callSpans, trace.SpanContextFromContext(ctx))
+			return ctx
+		}),
+		rv.Provide(func() *foo { return &foo{} }),
+		rv.Invoke(func(*foo) error { return errFailed }),
+	)
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("errors are not equal: \ngot: %v \nexp: %v", err, errFailed)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Fatalf("provide span must not fail: %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Fatalf("invoke span must fail: %v", spans[1].Status())
+	}
+	for i, span := range spans {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("span %s must be a child of the resolution span", span.Name())
+		}
+		if callSpans[i].SpanID() != span.SpanContext().SpanID() {
+			t.Fatalf("call context of %s must carry its span", span.Name())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package rv
+
+import "reflect"
+
+// ErrorSink is injected into functions starting background work to report its terminal error.
+// When an ErrorSink is consumed, Revolve blocks after all invokes are called until
+// the first error is reported, returning it, or until the context is done, returning nil.
+type ErrorSink interface {
+	Report(err error)
+}
+
+var errorSinkType = reflect.TypeOf((*ErrorSink)(nil)).Elem()
+
+type errorSink struct {
+	errs chan error
+}
+
+func (s errorSink) Report(err error) {
+	if err == nil {
+		return
+	}
+	select {
+	case s.errs <- err:
+	default: // the first error is already reported
+	}
+}
+
+func parseErrorSinkSupply(sink errorSink) *function {
+	return &function{
+		name:      "ErrorSink",
+		synthetic: true,
+		outputs: []output{{
+			typ:   errorSinkType,
+			value: reflect.ValueOf(sink),
+		}},
+		state: StateCalled,
+	}
+}
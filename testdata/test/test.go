@@ -5,3 +5,9 @@ type Bar struct{}
 func NewBar() (*Bar, error) {
 	return &Bar{}, nil
 }
+
+type Repository[T any] struct{}
+
+func NewRepository[T any]() *Repository[T] {
+	return &Repository[T]{}
+}